@@ -0,0 +1,303 @@
+package expr
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/rhysd/actionlint"
+)
+
+// CachingEvaluator is an Evaluator remembering the results of recently evaluated expressions. An
+// expression evaluated again against a context holding the same values returns the cached result
+// instead of being evaluated again. Expressions are normalized first, so `a.b == 1` and `A.B==1`
+// share a cache entry.
+//
+// Only the contexts referenced by an expression are looked up to compute its cache key. Expressions
+// calling hashFiles() or custom functions are never cached, as their results may change between
+// evaluations. Neither are evaluations with OnUntrustedInput or Tracer set, since they have to be
+// called every time. Failed evaluations are not cached. Results of different values of Status are
+// cached separately.
+//
+// Like the Evaluator, a CachingEvaluator can be used by multiple goroutines at the same time, as long
+// as its fields are not modified after the first evaluation. Between evaluations evaluating in
+// sequence, Status may be changed.
+type CachingEvaluator struct {
+	Evaluator
+
+	size int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+type cacheEntry struct {
+	key    string
+	result *EvaluationResult
+}
+
+// NewCachingEvaluator returns a CachingEvaluator keeping the results of the given number of most
+// recently used expressions, configured with the given options.
+func NewCachingEvaluator(size int, opts ...Option) *CachingEvaluator {
+	return &CachingEvaluator{
+		Evaluator: *NewEvaluator(opts...),
+		size:      size,
+		entries:   map[string]*list.Element{},
+		order:     list.New(),
+	}
+}
+
+// Evaluate parses and evaluates the given expression, without the surrounding `${{ }}`, or returns
+// the cached result of a previous evaluation.
+func (c *CachingEvaluator) Evaluate(expr string, context Context) (*EvaluationResult, error) {
+	n, err := parse(expr, c.MaxDepth)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.EvaluateNode(n, context)
+}
+
+// EvaluateNode evaluates an already parsed expression with the given context, or returns the cached
+// result of a previous evaluation. Like Evaluator.EvaluateNode, the tree is evaluated as it is, without
+// regrouping chains of comparisons.
+func (c *CachingEvaluator) EvaluateNode(n actionlint.ExprNode, context Context) (*EvaluationResult, error) {
+	if c.size <= 0 || c.OnUntrustedInput != nil || c.Tracer != nil || !c.cacheable(n) {
+		return c.Evaluator.EvaluateNode(n, context)
+	}
+
+	key := cacheKey(n, context, c.Status)
+	if result, ok := c.get(key); ok {
+		return result, nil
+	}
+
+	result, err := c.Evaluator.EvaluateNode(n, context)
+	if err != nil {
+		return nil, err
+	}
+
+	c.add(key, result)
+
+	return copyResult(result), nil
+}
+
+// EvaluateAll evaluates each of the given expressions like Evaluate, returning cached results where
+// possible. Unlike for Evaluator.EvaluateAll, contexts are looked up for every expression to compute
+// their cache keys.
+func (c *CachingEvaluator) EvaluateAll(exprs []string, context Context) ([]*EvaluationResult, []error) {
+	results := make([]*EvaluationResult, len(exprs))
+	evalErrors := make([]error, len(exprs))
+	for i, expr := range exprs {
+		results[i], evalErrors[i] = c.Evaluate(expr, context)
+	}
+
+	return results, evalErrors
+}
+
+// EvaluateCondition evaluates a condition like Evaluator.EvaluateCondition, or returns the cached
+// result of a previous evaluation.
+func (c *CachingEvaluator) EvaluateCondition(cond string, context Context) (bool, error) {
+	n, err := parseCondition(cond, c.MaxDepth)
+	if err != nil {
+		return false, err
+	}
+
+	result, err := c.EvaluateNode(n, context)
+	if err != nil {
+		return false, err
+	}
+
+	return result.IsTruthy(), nil
+}
+
+// EvaluateString evaluates all `${{ }}` expressions embedded in s like Evaluator.EvaluateString, using
+// cached results where possible.
+func (c *CachingEvaluator) EvaluateString(s string, context Context) (string, error) {
+	return evaluateString(s, func(expr string) (*EvaluationResult, error) {
+		return c.Evaluate(expr, context)
+	})
+}
+
+// Len returns the number of cached results.
+func (c *CachingEvaluator) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.order.Len()
+}
+
+func (c *CachingEvaluator) get(key string) (*EvaluationResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	c.order.MoveToFront(el)
+
+	// Callers must not be able to modify the cached value
+	return copyResult(el.Value.(*cacheEntry).result), true
+}
+
+func (c *CachingEvaluator) add(key string, result *EvaluationResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		el.Value.(*cacheEntry).result = result
+		c.order.MoveToFront(el)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&cacheEntry{key, result})
+
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// cacheable reports whether the result of the given expression only depends on the contexts and
+// options it is evaluated with.
+func (c *CachingEvaluator) cacheable(n actionlint.ExprNode) bool {
+	ok := true
+	actionlint.VisitExprNode(n, func(n, _ actionlint.ExprNode, entering bool) {
+		call, isCall := n.(*actionlint.FuncCallNode)
+		if !entering || !isCall {
+			return
+		}
+
+		key := strings.ToLower(call.Callee)
+		if funcDef, found := c.lookupFunction(key); key == "hashfiles" || found && funcDef.volatile {
+			ok = false
+		}
+	})
+
+	return ok
+}
+
+// cacheKey returns the key of the result of the given expression, made of the normalized expression,
+// the job status used by success() and failure(), and a fingerprint of the values of the contexts it
+// references.
+func cacheKey(n actionlint.ExprNode, context Context, status Status) string {
+	names := map[string]struct{}{}
+	actionlint.VisitExprNode(n, func(n, _ actionlint.ExprNode, entering bool) {
+		if v, ok := n.(*actionlint.VariableNode); ok && entering {
+			names[v.Name] = struct{}{}
+		}
+	})
+
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	h := sha256.New()
+	for _, name := range sorted {
+		var v interface{}
+		found := false
+		if context != nil {
+			v, found = context.Lookup(name)
+		}
+
+		writeString(h, name)
+		if !found {
+			// Missing contexts are distinct from contexts set to null
+			h.Write([]byte{'-'})
+			continue
+		}
+
+		writeFingerprint(h, v)
+	}
+
+	var sb strings.Builder
+	writeNode(&sb, n)
+	sb.WriteByte(0)
+	sb.WriteString(strconv.Itoa(int(status)))
+	sb.WriteByte(0)
+	sb.Write(h.Sum(nil))
+
+	return sb.String()
+}
+
+// writeFingerprint writes an unambiguous encoding of v to h. Secrets are written unmasked, so
+// contexts with different secrets never share results.
+func writeFingerprint(h hash.Hash, v interface{}) {
+	switch vt := v.(type) {
+	case nil:
+		h.Write([]byte{'n'})
+
+	case bool:
+		if vt {
+			h.Write([]byte{'t'})
+		} else {
+			h.Write([]byte{'f'})
+		}
+
+	case float64:
+		h.Write([]byte{'d'})
+		h.Write([]byte(strconv.FormatUint(math.Float64bits(vt), 16)))
+		h.Write([]byte{';'})
+
+	case json.Number:
+		h.Write([]byte{'j'})
+		writeString(h, string(vt))
+
+	case string:
+		h.Write([]byte{'s'})
+		writeString(h, vt)
+
+	case secretString:
+		h.Write([]byte{'x'})
+		writeString(h, string(vt))
+
+	case maskedString:
+		h.Write([]byte{'m'})
+		writeString(h, vt.value)
+		writeString(h, vt.masked)
+
+	case []interface{}:
+		h.Write([]byte{'['})
+		for _, v := range vt {
+			writeFingerprint(h, v)
+		}
+		h.Write([]byte{']'})
+
+	case ContextData:
+		keys := make([]string, 0, len(vt))
+		for k := range vt {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		h.Write([]byte{'{'})
+		for _, k := range keys {
+			writeString(h, k)
+			writeFingerprint(h, vt[k])
+		}
+		h.Write([]byte{'}'})
+
+	default:
+		h.Write([]byte{'?'})
+		writeString(h, fmt.Sprintf("%T:%#v", v, v))
+	}
+}
+
+// writeString writes s prefixed with its length, so consecutive strings cannot be confused.
+func writeString(h hash.Hash, s string) {
+	h.Write([]byte(strconv.Itoa(len(s))))
+	h.Write([]byte{':'})
+	h.Write([]byte(s))
+}
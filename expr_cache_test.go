@@ -0,0 +1,215 @@
+package expr
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCachingEvaluator_Hits(t *testing.T) {
+	c := NewCachingEvaluator(10)
+	ctx := &countingContext{MapContext: MapContext{"github": ContextData{"event_name": "push", "sha": "abc"}}}
+
+	first, err := c.Evaluate("fromJSON('{\"a\": [1, 2]}')", nil)
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+
+	for _, expr := range []string{"github.event_name == 'push'", "GITHUB.event_name=='push'", "(github.EVENT_NAME == 'push')"} {
+		got, err := c.Evaluate(expr, ctx)
+		if err != nil {
+			t.Fatalf("Evaluate(%q) error = %v", expr, err)
+		}
+
+		if got.Value != true {
+			t.Errorf("Evaluate(%q) = %v, want %v", expr, got.Value, true)
+		}
+	}
+
+	// Only the first evaluation reads the context through the evaluator, the others only compute the
+	// cache key
+	if want := []string{"github", "github", "github", "github"}; !reflect.DeepEqual(ctx.lookups, want) {
+		t.Errorf("Lookup() called with %v, want %v", ctx.lookups, want)
+	}
+
+	if c.Len() != 2 {
+		t.Errorf("Len() = %d, want %d", c.Len(), 2)
+	}
+
+	second, err := c.Evaluate("fromJSON('{\"a\": [1, 2]}')", nil)
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("Evaluate() = %v, want %v", second.Value, first.Value)
+	}
+
+	// Cached values are not shared with callers
+	first.Value.(ContextData)["a"] = nil
+	third, _ := c.Evaluate("fromJSON('{\"a\": [1, 2]}')", nil)
+	if !reflect.DeepEqual(second, third) {
+		t.Errorf("Evaluate() = %v, want %v", third.Value, second.Value)
+	}
+
+	// Neither are cached results of primitive values
+	for i := 0; i < 3; i++ {
+		got, err := c.Evaluate("github.event_name == 'push'", ctx)
+		if err != nil {
+			t.Fatalf("Evaluate() error = %v", err)
+		}
+
+		if got.Value != true {
+			t.Errorf("Evaluate() = %v, want %v", got.Value, true)
+		}
+
+		got.Value = false
+	}
+}
+
+func TestCachingEvaluator_DifferentContexts(t *testing.T) {
+	c := NewCachingEvaluator(10)
+	c.MaskSecrets = true
+
+	tests := []struct {
+		context MapContext
+		want    interface{}
+	}{
+		{MapContext{"env": ContextData{"a": "x"}}, "x"},
+		{MapContext{"env": ContextData{"a": "y"}}, "y"},
+		{MapContext{"env": ContextData{"A": "y"}}, "y"},
+		{MapContext{"env": ContextData{"a": 1.5}}, "1.5"},
+		{MapContext{"env": ContextData{"a": "1"}}, "1"},
+		{MapContext{"env": ContextData{"a": []interface{}{"x"}}}, "Array"},
+		{MapContext{"env": ContextData{"a": nil}}, ""},
+		{MapContext{"env": ContextData{}}, ""},
+		{MapContext{"env": nil}, ""},
+		{MapContext{}, ""},
+		{MapContext{"env": ContextData{"a": "x"}, "secrets": ContextData{"token": "1"}}, "x"},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 2; i++ {
+			got, err := c.Evaluate("format('{0}', env.a)", tt.context)
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+
+			if got.Value != tt.want {
+				t.Errorf("Evaluate() with %v = %v, want %v", tt.context, got.Value, tt.want)
+			}
+		}
+	}
+
+	// Secrets are masked in results, but different secrets still use different entries
+	for _, token := range []string{"1", "2"} {
+		got, err := c.Evaluate("secrets.token == '2'", MapContext{"secrets": ContextData{"token": token}})
+		if err != nil {
+			t.Fatalf("Evaluate() error = %v", err)
+		}
+
+		if want := token == "2"; got.Value != want {
+			t.Errorf("Evaluate() with token %s = %v, want %v", token, got.Value, want)
+		}
+	}
+}
+
+func TestCachingEvaluator_Status(t *testing.T) {
+	c := NewCachingEvaluator(10)
+
+	for _, status := range []Status{StatusSuccess, StatusFailure, StatusSuccess} {
+		c.Status = status
+		got, err := c.Evaluate("success()", nil)
+		if err != nil {
+			t.Fatalf("Evaluate() error = %v", err)
+		}
+
+		if want := status == StatusSuccess; got.Value != want {
+			t.Errorf("Evaluate() with status %v = %v, want %v", status, got.Value, want)
+		}
+	}
+
+	if c.Len() != 2 {
+		t.Errorf("Len() = %d, want %d", c.Len(), 2)
+	}
+}
+
+func TestCachingEvaluator_Wrappers(t *testing.T) {
+	c := NewCachingEvaluator(10)
+	ctx := &countingContext{MapContext: MapContext{"env": ContextData{"a": "x"}}}
+
+	for i := 0; i < 2; i++ {
+		if got, err := c.EvaluateCondition("${{ env.a == 'x' }}", ctx); err != nil || !got {
+			t.Errorf("EvaluateCondition() = %v, %v, want true", got, err)
+		}
+
+		if got, err := c.EvaluateString("a ${{ env.a }} b", ctx); err != nil || got != "a x b" {
+			t.Errorf("EvaluateString() = %q, %v, want %q", got, err, "a x b")
+		}
+
+		results, errs := c.EvaluateAll([]string{"env.a", "env.b", "env.a =="}, ctx)
+		if results[0].Value != "x" || results[1].Value != nil || errs[2] == nil {
+			t.Errorf("EvaluateAll() = %v, %v, want [x <nil>] and a parse error", results, errs)
+		}
+	}
+
+	// The condition, env.a, and env.b are cached, the embedded expression shares its entry with the
+	// first expression of the batch
+	if c.Len() != 3 {
+		t.Errorf("Len() = %d, want %d", c.Len(), 3)
+	}
+
+	// Each of the four valid expressions reads the context to compute its cache key, the three misses
+	// read it once more
+	if got, want := len(ctx.lookups), 2*4+3; got != want {
+		t.Errorf("Lookup() called %d times, want %d", got, want)
+	}
+}
+
+func TestCachingEvaluator_Eviction(t *testing.T) {
+	c := NewCachingEvaluator(2)
+	ctx := &countingContext{MapContext: MapContext{"env": ContextData{"a": "x", "b": "y", "c": "z"}}}
+
+	for _, expr := range []string{"env.a", "env.b", "env.a", "env.c", "env.a", "env.b"} {
+		if _, err := c.Evaluate(expr, ctx); err != nil {
+			t.Fatalf("Evaluate(%q) error = %v", expr, err)
+		}
+	}
+
+	// env.b is evicted by env.c, each miss looks up the context twice
+	if got, want := len(ctx.lookups), 2*4+2; got != want {
+		t.Errorf("Lookup() called %d times, want %d", got, want)
+	}
+
+	if c.Len() != 2 {
+		t.Errorf("Len() = %d, want %d", c.Len(), 2)
+	}
+}
+
+func TestCachingEvaluator_NotCached(t *testing.T) {
+	OverrideFunction("cacheCounter", 0, func(args ...*EvaluationResult) (*EvaluationResult, error) {
+		return NewBool(true), nil
+	})
+
+	c := NewCachingEvaluator(10, WithFunctions(map[string]FunctionDefinition{"scoped": {0, func(args ...*EvaluationResult) (*EvaluationResult, error) {
+		return NewBool(true), nil
+	}}}))
+	for _, expr := range []string{"cacheCounter()", "scoped()", "hashFiles('does-not-exist')", "fromJSON('{')", "env.a == 1 && cacheCounter()"} {
+		c.Evaluate(expr, nil)
+	}
+
+	if c.Len() != 0 {
+		t.Errorf("Len() = %d, want %d", c.Len(), 0)
+	}
+
+	var untrusted []string
+	c.OnUntrustedInput = func(path string) { untrusted = append(untrusted, path) }
+	ctx := MapContext{"github": ContextData{"event": ContextData{"issue": ContextData{"title": "x"}}}}
+	for i := 0; i < 2; i++ {
+		if _, err := c.Evaluate("github.event.issue.title", ctx); err != nil {
+			t.Fatalf("Evaluate() error = %v", err)
+		}
+	}
+
+	if len(untrusted) != 2 {
+		t.Errorf("OnUntrustedInput called with %v, want 2 calls", untrusted)
+	}
+}
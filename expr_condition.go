@@ -0,0 +1,76 @@
+package expr
+
+import (
+	"strings"
+
+	"github.com/rhysd/actionlint"
+)
+
+// statusFunctions are the functions checking the job status. Conditions calling none of them are only
+// true if all previous steps succeeded.
+var statusFunctions = []string{"success", "always", "cancelled", "failure"}
+
+// EvaluateCondition evaluates the given `if:` condition using the default evaluator.
+func EvaluateCondition(cond string, context Context) (bool, error) {
+	return (&Evaluator{}).EvaluateCondition(cond, context)
+}
+
+// EvaluateCondition evaluates a condition like the value of an `if:` key and reports whether it is
+// true. Like on GitHub, the condition can be written with or without the surrounding `${{ }}`, and a
+// condition not calling any of success(), always(), cancelled(), or failure() is implicitly combined
+// with success(), so it is false if the Status is not StatusSuccess.
+func (e *Evaluator) EvaluateCondition(cond string, context Context) (bool, error) {
+	n, err := parseCondition(cond, e.MaxDepth)
+	if err != nil {
+		return false, err
+	}
+
+	result, err := e.evaluateParsed(n, newEvaluation(context))
+	if err != nil {
+		return false, err
+	}
+
+	return result.IsTruthy(), nil
+}
+
+// parseCondition parses a condition with or without the surrounding `${{ }}`, combining it with
+// success() if it does not call any of the statusFunctions.
+func parseCondition(cond string, maxDepth int) (actionlint.ExprNode, error) {
+	cond = strings.TrimSpace(cond)
+	if strings.HasPrefix(cond, "${{") && strings.HasSuffix(cond, "}}") {
+		cond = cond[len("${{") : len(cond)-len("}}")]
+	}
+
+	n, err := parse(cond, maxDepth)
+	if err != nil {
+		return nil, err
+	}
+
+	if !callsStatusFunction(n) {
+		n = &actionlint.LogicalOpNode{
+			Kind:  actionlint.LogicalOpNodeKindAnd,
+			Left:  &actionlint.FuncCallNode{Callee: "success", Args: []actionlint.ExprNode{}},
+			Right: n,
+		}
+	}
+
+	return n, nil
+}
+
+func callsStatusFunction(n actionlint.ExprNode) bool {
+	found := false
+	actionlint.VisitExprNode(n, func(n, _ actionlint.ExprNode, entering bool) {
+		call, ok := n.(*actionlint.FuncCallNode)
+		if !ok || !entering {
+			return
+		}
+
+		for _, name := range statusFunctions {
+			if strings.EqualFold(call.Callee, name) {
+				found = true
+			}
+		}
+	})
+
+	return found
+}
@@ -0,0 +1,58 @@
+package expr
+
+import "testing"
+
+func TestEvaluateCondition(t *testing.T) {
+	ctx := MapContext{
+		"github": ContextData{"ref": "refs/heads/main", "event_name": "push"},
+	}
+
+	tests := []struct {
+		cond   string
+		status Status
+		want   bool
+	}{
+		{"success() && github.ref == 'refs/heads/main'", StatusSuccess, true},
+		{"success() && github.ref == 'refs/heads/main'", StatusFailure, false},
+		{"success() && github.ref == 'refs/heads/dev'", StatusSuccess, false},
+		{"${{ success() && github.ref == 'refs/heads/main' }}", StatusSuccess, true},
+		{"  ${{ github.event_name == 'push' }}  ", StatusSuccess, true},
+
+		// Conditions without status functions only run after successful steps
+		{"github.event_name == 'push'", StatusSuccess, true},
+		{"github.event_name == 'push'", StatusFailure, false},
+		{"github.event_name == 'push'", StatusCancelled, false},
+		{"always() && github.event_name == 'push'", StatusFailure, true},
+		{"failure()", StatusFailure, true},
+		{"failure() || github.event_name == 'push'", StatusSuccess, true},
+		{"FAILURE()", StatusFailure, true},
+		{"!cancelled()", StatusFailure, true},
+
+		// Results are converted to booleans
+		{"github.ref", StatusSuccess, true},
+		{"github.unknown", StatusSuccess, false},
+		{"''", StatusSuccess, false},
+		{"0", StatusSuccess, false},
+		{"fromJSON('[]')", StatusSuccess, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.cond, func(t *testing.T) {
+			got, err := (&Evaluator{Status: tt.status}).EvaluateCondition(tt.cond, ctx)
+			if err != nil {
+				t.Fatalf("EvaluateCondition() error = %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("EvaluateCondition() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateCondition_Errors(t *testing.T) {
+	for _, cond := range []string{"", "${{ }}", "github.ref ==", "unknown()"} {
+		if _, err := EvaluateCondition(cond, nil); err == nil {
+			t.Errorf("EvaluateCondition(%q) did not fail", cond)
+		}
+	}
+}
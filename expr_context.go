@@ -0,0 +1,89 @@
+package expr
+
+import "strings"
+
+// Context provides the values of the top-level contexts an expression can reference, like `github`,
+// `env`, `job`, `steps`, `runner`, `secrets`, `strategy`, `matrix`, `needs`, `inputs`, or `vars`.
+type Context interface {
+	// Lookup returns the value of the context with the given name. Names are always lower case.
+	Lookup(name string) (interface{}, bool)
+}
+
+// KnownContexts are the names of the top-level contexts available on GitHub. With
+// Evaluator.UnknownContextIsError, referencing any other context fails.
+var KnownContexts = []string{"github", "env", "job", "steps", "runner", "secrets", "strategy", "matrix", "needs", "inputs", "vars"}
+
+func isKnownContext(name string) bool {
+	for _, c := range KnownContexts {
+		if c == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// MapContext is a Context backed by a map from context names to their values. Objects are expected to
+// be ContextData, arrays []interface{}.
+type MapContext map[string]interface{}
+
+func (c MapContext) Lookup(name string) (interface{}, bool) {
+	return lookupKey(c, name)
+}
+
+// lookupKey returns the value of the given key in obj. Like all property access in expressions, keys
+// are matched case-insensitively.
+func lookupKey(obj map[string]interface{}, key string) (interface{}, bool) {
+	if k, ok := matchKey(obj, key); ok {
+		return obj[k], true
+	}
+
+	return nil, false
+}
+
+// matchKey returns the key of obj matching the given key case-insensitively. An exact match is
+// preferred, otherwise the lexically first of the keys differing only in case is returned, so that the
+// result does not depend on the iteration order of the map.
+func matchKey(obj map[string]interface{}, key string) (string, bool) {
+	if _, ok := obj[key]; ok {
+		return key, true
+	}
+
+	match, ok := "", false
+	for k := range obj {
+		if strings.EqualFold(k, key) && (!ok || k < match) {
+			match, ok = k, true
+		}
+	}
+
+	return match, ok
+}
+
+// NeededJob is the result of a job listed in `needs`, used to build the `needs` context with
+// NeedsContext.
+type NeededJob struct {
+	// Result is one of success, failure, cancelled, or skipped.
+	Result string
+
+	// Outputs are the outputs of the job.
+	Outputs map[string]string
+}
+
+// NeedsContext returns the value of the `needs` context for the given jobs, keyed by job ID.
+// Unknown jobs and outputs evaluate to null.
+func NeedsContext(jobs map[string]NeededJob) ContextData {
+	needs := make(ContextData, len(jobs))
+	for id, job := range jobs {
+		outputs := make(ContextData, len(job.Outputs))
+		for name, value := range job.Outputs {
+			outputs[name] = value
+		}
+
+		needs[id] = ContextData{
+			"result":  job.Result,
+			"outputs": outputs,
+		}
+	}
+
+	return needs
+}
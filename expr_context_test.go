@@ -0,0 +1,349 @@
+package expr
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/rhysd/actionlint"
+)
+
+type countingContext struct {
+	MapContext
+	lookups []string
+}
+
+func (c *countingContext) Lookup(name string) (interface{}, bool) {
+	c.lookups = append(c.lookups, name)
+	return c.MapContext.Lookup(name)
+}
+
+func TestMapContext(t *testing.T) {
+	ctx := MapContext{
+		"github": ContextData{
+			"event_name": "pull_request",
+			"event": ContextData{
+				"pull_request": ContextData{
+					"number": float64(42),
+				},
+			},
+		},
+		"env": ContextData{
+			"FOO": "bar",
+		},
+		"secrets": ContextData{
+			"TOKEN": "s3cr3t",
+		},
+	}
+
+	tests := []struct {
+		input string
+		want  *EvaluationResult
+	}{
+		{"github.event.pull_request.number", &EvaluationResult{float64(42), &actionlint.NumberType{}}},
+		{"github.event_name == 'pull_request'", &EvaluationResult{true, &actionlint.BoolType{}}},
+		{"github['event']['pull_request'].number", &EvaluationResult{float64(42), &actionlint.NumberType{}}},
+		{"env.FOO", &EvaluationResult{"bar", &actionlint.StringType{}}},
+		{"ENV.foo", &EvaluationResult{"bar", &actionlint.StringType{}}},
+		{"secrets.TOKEN", &EvaluationResult{"s3cr3t", &actionlint.StringType{}}},
+		{"github.unknown", &EvaluationResult{nil, &actionlint.NullType{}}},
+		{"unknown", &EvaluationResult{nil, &actionlint.NullType{}}},
+		{"env.UNKNOWN == ''", &EvaluationResult{true, &actionlint.BoolType{}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := Evaluate(tt.input, ctx)
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Evaluate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContext_Lookup(t *testing.T) {
+	ctx := &countingContext{MapContext: MapContext{"github": ContextData{"sha": "abc"}}}
+
+	got, err := Evaluate("GITHUB.sha == 'abc' && env.FOO == null", ctx)
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+
+	if got.Value != true {
+		t.Errorf("Evaluate() = %v, want %v", got.Value, true)
+	}
+
+	if want := []string{"github", "env"}; !reflect.DeepEqual(ctx.lookups, want) {
+		t.Errorf("Lookup() called with %v, want %v", ctx.lookups, want)
+	}
+}
+
+func TestMapContext_AmbiguousKeys(t *testing.T) {
+	ctx := MapContext{
+		"env": ContextData{"Foo": "Foo", "FOO": "FOO", "foo": "foo", "Bar": "Bar", "bAR": "bAR", "baR": "baR"},
+	}
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		// Exact matches are preferred, the parser converts property names to lower case
+		{"env['Foo']", "Foo"},
+		{"env['FOO']", "FOO"},
+		{"env.FOO", "foo"},
+
+		// Otherwise the lexically first key is used
+		{"env['fOO']", "FOO"},
+		{"env.bar", "Bar"},
+		{"env['BAR']", "Bar"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			// Map iteration order is random, the same key has to be found every time
+			for i := 0; i < 20; i++ {
+				got, err := Evaluate(tt.input, ctx)
+				if err != nil {
+					t.Fatalf("Evaluate() error = %v", err)
+				}
+
+				if got.Value != tt.want {
+					t.Fatalf("Evaluate() = %v, want %v", got.Value, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestMapContext_MatrixAndStrategy(t *testing.T) {
+	ctx := MapContext{
+		"matrix": ContextData{
+			"os":   []interface{}{"ubuntu-latest", "windows-latest"},
+			"node": float64(16),
+			"include": []interface{}{
+				ContextData{"os": "macos-latest", "experimental": true},
+			},
+		},
+		"strategy": ContextData{
+			"fail-fast":    true,
+			"job-index":    float64(1),
+			"job-total":    float64(4),
+			"max-parallel": float64(2),
+		},
+	}
+
+	tests := []struct {
+		input string
+		want  *EvaluationResult
+	}{
+		{"contains(matrix.os, 'ubuntu-latest')", &EvaluationResult{true, &actionlint.BoolType{}}},
+		{"contains(matrix.os, 'UBUNTU-LATEST')", &EvaluationResult{true, &actionlint.BoolType{}}},
+		{"contains(matrix.os, 'macos-latest')", &EvaluationResult{false, &actionlint.BoolType{}}},
+		{"contains(matrix.include.*.os, 'macos-latest')", &EvaluationResult{true, &actionlint.BoolType{}}},
+		{"matrix.include[0].experimental", &EvaluationResult{true, &actionlint.BoolType{}}},
+		{"matrix.os[1]", &EvaluationResult{"windows-latest", &actionlint.StringType{}}},
+		{"matrix.node == 16", &EvaluationResult{true, &actionlint.BoolType{}}},
+		{"matrix.unknown", &EvaluationResult{nil, &actionlint.NullType{}}},
+		{"matrix.unknown.nested", &EvaluationResult{nil, &actionlint.NullType{}}},
+		{"strategy.job-index", &EvaluationResult{float64(1), &actionlint.NumberType{}}},
+		{"strategy['job-total'] == 4", &EvaluationResult{true, &actionlint.BoolType{}}},
+		{"strategy.fail-fast && strategy.max-parallel > 1", &EvaluationResult{true, &actionlint.BoolType{}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := Evaluate(tt.input, ctx)
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Evaluate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMapContext_MatrixFromJSON(t *testing.T) {
+	// Matrix values expanded from fromJSON() are decoded into the same types as other context values
+	ctx := MapContext{"matrix": ContextData{}}
+
+	got, err := Evaluate(`contains(fromJSON('{"os": ["ubuntu-latest"]}').os, matrix.os || 'ubuntu-latest')`, ctx)
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+
+	if got.Value != true {
+		t.Errorf("Evaluate() = %v, want %v", got.Value, true)
+	}
+}
+
+func TestMapContext_MissingProperties(t *testing.T) {
+	ctx := MapContext{
+		"github": ContextData{
+			"event_name": "push",
+			"event": ContextData{
+				"ref":     "refs/heads/main",
+				"commits": []interface{}{},
+			},
+		},
+	}
+
+	tests := []string{
+		// Missing intermediate objects
+		"github.event.pull_request.number",
+		"github.event.pull_request.head.repo.full_name",
+		"github.event['pull_request']['number']",
+		"github['event'].pull_request['head'].ref",
+		"github.event.commits[0].author.name",
+		"unknown.a.b.c",
+		"unknown['a'][0]",
+
+		// Properties of values that are not objects
+		"github.event_name.length",
+		"github.event.ref.x.y",
+		"github.event.commits.length",
+	}
+	for _, input := range tests {
+		t.Run(input, func(t *testing.T) {
+			got, err := Evaluate(input, ctx)
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+
+			if want := (&EvaluationResult{nil, &actionlint.NullType{}}); !reflect.DeepEqual(got, want) {
+				t.Errorf("Evaluate() = %v, want %v", got, want)
+			}
+		})
+	}
+
+	got, err := Evaluate("github.event.pull_request.number == null && !github.event.pull_request.draft", ctx)
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+
+	if got.Value != true {
+		t.Errorf("Evaluate() = %v, want true", got.Value)
+	}
+}
+
+func TestEvaluator_UnknownContextIsError(t *testing.T) {
+	ctx := MapContext{"github": ContextData{"sha": "abc"}}
+
+	tests := []struct {
+		input   string
+		wantErr string
+	}{
+		{"typo.foo", "unknown context typo"},
+		{"github.sha == 'abc' && Gihtub.sha", "unknown context gihtub"},
+		{"format('{0}', unknown)", "unknown context unknown"},
+		{"github.sha", ""},
+		{"vars.NAME == null", ""},
+		{"needs.build.result", ""},
+		{"INPUTS.name", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			// Unknown contexts are null by default
+			if _, err := Evaluate(tt.input, ctx); err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+
+			_, err := (&Evaluator{UnknownContextIsError: true}).Evaluate(tt.input, ctx)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Evaluate() error = %v", err)
+				}
+				return
+			}
+
+			var cerr *UnknownContextError
+			if !errors.As(err, &cerr) {
+				t.Fatalf("Evaluate() error = %v, want UnknownContextError", err)
+			}
+
+			if cerr.Error() != tt.wantErr {
+				t.Errorf("Evaluate() error = %v, want %v", cerr, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNeedsContext(t *testing.T) {
+	ctx := MapContext{
+		"needs": NeedsContext(map[string]NeededJob{
+			"build": {Result: "success", Outputs: map[string]string{"version": "1.2.3"}},
+			"lint":  {Result: "skipped"},
+		}),
+	}
+
+	tests := []struct {
+		input string
+		want  *EvaluationResult
+	}{
+		{"needs.build.result == 'success'", &EvaluationResult{true, &actionlint.BoolType{}}},
+		{"needs.build.outputs.version", &EvaluationResult{"1.2.3", &actionlint.StringType{}}},
+		{"needs.build.outputs.VERSION", &EvaluationResult{"1.2.3", &actionlint.StringType{}}},
+		{"needs.lint.result", &EvaluationResult{"skipped", &actionlint.StringType{}}},
+		{"needs.lint.outputs.version", &EvaluationResult{nil, &actionlint.NullType{}}},
+		{"needs.build.outputs.unknown", &EvaluationResult{nil, &actionlint.NullType{}}},
+		{"needs.deploy.result", &EvaluationResult{nil, &actionlint.NullType{}}},
+		{"needs.deploy.result == 'success'", &EvaluationResult{false, &actionlint.BoolType{}}},
+		{"contains(needs.*.result, 'skipped')", &EvaluationResult{true, &actionlint.BoolType{}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := Evaluate(tt.input, ctx)
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Evaluate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluate_NilContext(t *testing.T) {
+	tests := []struct {
+		input string
+		want  interface{}
+	}{
+		{"contains('abc', 'b')", true},
+		{"format('{0}-{1}', 'a', 1)", "a-1"},
+		{"fromJSON('{\"a\": [1]}').a[0]", float64(1)},
+		{"github", nil},
+		{"github.event.issue.title", nil},
+		{"github['ref']", nil},
+		{"matrix.*.os", []interface{}{}},
+		{"github.ref == null", true},
+		{"env.NAME || 'default'", "default"},
+		{"toJSON(github)", "null"},
+		{"format('{0}', secrets.token)", ""},
+		{"startsWith(github.ref, 'refs/')", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			for _, ev := range []*Evaluator{{}, {MaskSecrets: true}} {
+				got, err := ev.Evaluate(tt.input, nil)
+				if err != nil {
+					t.Fatalf("Evaluate() error = %v", err)
+				}
+
+				if !reflect.DeepEqual(got.Value, tt.want) {
+					t.Errorf("Evaluate() = %#v, want %#v", got.Value, tt.want)
+				}
+			}
+		})
+	}
+
+	if _, err := (&Evaluator{UnknownContextIsError: true}).Evaluate("unknown.a", nil); err == nil {
+		t.Errorf("Evaluate() of unknown context did not fail")
+	}
+
+	if s, err := EvaluateString("ref: ${{ github.ref }}", nil); err != nil || s != "ref: " {
+		t.Errorf("EvaluateString() = %q, %v, want %q", s, err, "ref: ")
+	}
+}
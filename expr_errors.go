@@ -0,0 +1,97 @@
+package expr
+
+import "github.com/rhysd/actionlint"
+
+// EvaluationError is returned when a function fails during evaluation, for example because it was
+// called with invalid input.
+type EvaluationError struct {
+	// Func is the name of the function as written in the expression.
+	Func string
+
+	// Err is the underlying error.
+	Err error
+
+	pos *actionlint.Pos
+}
+
+// Pos returns the position of the failed function call in the expression, or nil if it is unknown.
+func (e *EvaluationError) Pos() *actionlint.Pos {
+	return e.pos
+}
+
+func (e *EvaluationError) Error() string {
+	return "error calling " + e.Func + ": " + e.Err.Error()
+}
+
+func (e *EvaluationError) Unwrap() error {
+	return e.Err
+}
+
+// UndefinedFunctionError is returned when an expression calls a function that does not exist.
+type UndefinedFunctionError struct {
+	// Name is the name of the function as written in the expression.
+	Name string
+
+	// Suggestion is the name of the known function closest to Name, or empty if none is close enough.
+	Suggestion string
+
+	pos *actionlint.Pos
+}
+
+// Pos returns the position of the function call in the expression, or nil if it is unknown.
+func (e *UndefinedFunctionError) Pos() *actionlint.Pos {
+	return e.pos
+}
+
+func (e *UndefinedFunctionError) Error() string {
+	msg := "undefined function " + e.Name
+	if e.Suggestion != "" {
+		msg += ", did you mean " + e.Suggestion + "?"
+	}
+
+	return msg
+}
+
+// DisabledFunctionError is returned when an expression calls one of Evaluator.DisabledFunctions.
+type DisabledFunctionError struct {
+	// Name is the name of the function as written in the expression.
+	Name string
+
+	pos *actionlint.Pos
+}
+
+// Pos returns the position of the function call in the expression, or nil if it is unknown.
+func (e *DisabledFunctionError) Pos() *actionlint.Pos {
+	return e.pos
+}
+
+func (e *DisabledFunctionError) Error() string {
+	return "function " + e.Name + " is disabled"
+}
+
+// UnknownContextError is returned for references to contexts that are not in KnownContexts, if
+// Evaluator.UnknownContextIsError is set.
+type UnknownContextError struct {
+	// Name is the name of the context.
+	Name string
+
+	pos *actionlint.Pos
+}
+
+func (e *UnknownContextError) Error() string {
+	return "unknown context " + e.Name
+}
+
+// Pos returns the position of the context reference in the expression, or nil if it is unknown.
+func (e *UnknownContextError) Pos() *actionlint.Pos {
+	return e.pos
+}
+
+// tokenPos returns the position of the given token, if any.
+func tokenPos(t *actionlint.Token) *actionlint.Pos {
+	if t == nil {
+		return nil
+	}
+
+	return &actionlint.Pos{Line: t.Line, Col: t.Column}
+}
@@ -0,0 +1,153 @@
+package expr
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/rhysd/actionlint"
+)
+
+// evaluation holds the state of a single call to EvaluateNode. Context lookups and function results
+// are cached for the duration of the evaluation, so repeated subexpressions like the same fromJSON()
+// call are only computed once.
+type evaluation struct {
+	context Context
+
+	contexts map[string]interface{}
+	calls    map[string]*EvaluationResult
+
+	// depth is the number of nodes currently being evaluated
+	depth int
+
+	// untrusted holds the untrusted inputs that evaluated nodes refer to
+	untrusted map[actionlint.ExprNode]*actionlint.UntrustedInputMap
+
+	// emptyArrays are the arrays replacing the empty arrays of the contexts, see distinctEmptyArray
+	emptyArrays map[elementKey][]interface{}
+}
+
+// elementKey identifies an element of an array or object by the address of its parent and its key or
+// index.
+type elementKey struct {
+	parent uintptr
+	key    string
+}
+
+func newEvaluation(context Context) *evaluation {
+	return &evaluation{
+		context:  context,
+		contexts: map[string]interface{}{},
+		calls:    map[string]*EvaluationResult{},
+
+		untrusted: map[actionlint.ExprNode]*actionlint.UntrustedInputMap{},
+
+		emptyArrays: map[elementKey][]interface{}{},
+	}
+}
+
+// distinctEmptyArray returns v, the element with the given key or index of the array or object
+// parent. If v is an empty array without memory of its own, like the empty arrays decoded by
+// json.Unmarshal, which all share the same address, an empty array of its own is returned instead, so
+// that different empty arrays of the contexts are different references. The same element yields the
+// same array for the whole evaluation.
+func (s *evaluation) distinctEmptyArray(parent interface{}, key string, v interface{}) interface{} {
+	if a, ok := v.([]interface{}); !ok || cap(a) > 0 {
+		return v
+	}
+
+	k := elementKey{reflect.ValueOf(parent).Pointer(), key}
+	if a, ok := s.emptyArrays[k]; ok {
+		return a
+	}
+
+	a := newArray(0)
+	s.emptyArrays[k] = a
+
+	return a
+}
+
+// lookup returns the value of the named context. Unknown contexts evaluate to null.
+func (s *evaluation) lookup(name string, mask bool) interface{} {
+	if v, ok := s.contexts[name]; ok {
+		return v
+	}
+
+	var v interface{}
+	if s.context != nil {
+		v, _ = s.context.Lookup(name)
+	}
+
+	// Contexts are only looked up once, so an empty array of their own is the same for the whole
+	// evaluation
+	if a, ok := v.([]interface{}); ok && cap(a) == 0 {
+		v = newArray(0)
+	}
+
+	if mask && name == "secrets" {
+		v = maskSecrets(v)
+	}
+
+	s.contexts[name] = v
+
+	return v
+}
+
+// copyResult returns a copy of the given result, holding a copy of its array or object value. Composite
+// values are compared by reference, so reusing a memoized result for a second call would make both
+// calls equal to each other. Primitive values are shared, but the result itself is always new, so
+// callers modifying it do not change the original.
+func copyResult(r *EvaluationResult) *EvaluationResult {
+	if r.Primitive() {
+		return &EvaluationResult{r.Value, r.Type}
+	}
+
+	return r.Clone()
+}
+
+func copyValue(v interface{}) interface{} {
+	switch vt := v.(type) {
+	case ContextData:
+		r := make(ContextData, len(vt))
+		for k, v := range vt {
+			r[k] = copyValue(v)
+		}
+
+		return r
+
+	case []interface{}:
+		r := newArray(len(vt))
+		for i, v := range vt {
+			r[i] = copyValue(v)
+		}
+
+		return r
+	}
+
+	return v
+}
+
+// memoizeKey returns the key to memoize a function call with. Only calls with primitive arguments can
+// be memoized. Masked secrets get a different key than plain strings with the same text, so results
+// computed from one are never returned for the other.
+func memoizeKey(name string, args []*EvaluationResult) (string, bool) {
+	var sb strings.Builder
+	sb.WriteString(name)
+
+	for _, arg := range args {
+		if !arg.Primitive() {
+			return "", false
+		}
+
+		sb.WriteByte(0)
+		sb.WriteString(reflect.TypeOf(arg.Type).String())
+		if masked, ok := maskedValue(arg.Value); ok {
+			sb.WriteString("secret")
+			sb.WriteByte(0)
+			sb.WriteString(masked)
+		}
+		sb.WriteByte(0)
+		sb.WriteString(arg.unmaskedString())
+	}
+
+	return sb.String(), true
+}
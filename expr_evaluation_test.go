@@ -0,0 +1,144 @@
+package expr
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/rhysd/actionlint"
+)
+
+func TestEvaluation_CachesLookups(t *testing.T) {
+	ctx := &countingContext{MapContext: MapContext{"github": ContextData{"sha": "abc", "ref": "main"}}}
+
+	for i := 0; i < 2; i++ {
+		got, err := Evaluate("github.sha == 'abc' && github.ref == 'main'", ctx)
+		if err != nil {
+			t.Fatalf("Evaluate() error = %v", err)
+		}
+
+		if got.Value != true {
+			t.Errorf("Evaluate() = %v, want %v", got.Value, true)
+		}
+	}
+
+	// Cached during a single evaluation, but not across evaluations
+	if want := []string{"github", "github"}; !reflect.DeepEqual(ctx.lookups, want) {
+		t.Errorf("Lookup() called with %v, want %v", ctx.lookups, want)
+	}
+}
+
+func TestEvaluation_MemoizesBuiltinCalls(t *testing.T) {
+	got, err := Evaluate("fromJSON('{\"a\": 1}').a == fromJSON('{\"a\": 1}').a && fromJSON('{\"a\": 2}').a == 2", nil)
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+
+	if got.Value != true {
+		t.Errorf("Evaluate() = %v, want %v", got.Value, true)
+	}
+}
+
+func TestEvaluation_MemoizesSecretsSeparately(t *testing.T) {
+	ev := &Evaluator{MaskSecrets: true}
+	ctx := MapContext{"secrets": ContextData{"TOKEN": `{"a": "hunter2"}`}}
+
+	tests := []struct {
+		input string
+		want  interface{}
+	}{
+		// Plain string first
+		{`format('x{0}', '{"a": "hunter2"}') == 'nope' || format('x{0}', secrets.TOKEN)`, "x***"},
+		{`toJSON('{"a": "hunter2"}') == 'nope' || toJSON(secrets.TOKEN)`, `"***"`},
+		{`join('{"a": "hunter2"}') == 'nope' || join(secrets.TOKEN)`, "***"},
+
+		// Secret first
+		{`format('x{0}', secrets.TOKEN) == 'nope' || format('x{0}', '{"a": "hunter2"}')`, `x{"a": "hunter2"}`},
+		{`toJSON(secrets.TOKEN) == 'nope' || toJSON('{"a": "hunter2"}')`, `"{\"a\": \"hunter2\"}"`},
+		{`join(secrets.TOKEN) == 'nope' || join('{"a": "hunter2"}')`, `{"a": "hunter2"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ev.Evaluate(tt.input, ctx)
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+
+			if got.CoerceString() != tt.want {
+				t.Errorf("Evaluate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Expressions evaluated together share memoized calls. fromJSON() parses the actual value of
+	// secrets.
+	exprs := []string{
+		"format('x{0}', 'hunter2')",
+		"format('x{0}', secrets.PASSWORD)",
+		"format('y{0}', secrets.PASSWORD)",
+		"format('y{0}', 'hunter2')",
+		`fromJSON('{"a": 1}').a`,
+		"fromJSON(secrets.TOKEN).a",
+		"fromJSON(secrets.JSON).a",
+		`fromJSON('{"a": 2}').a`,
+	}
+	ctx = MapContext{"secrets": ContextData{"PASSWORD": "hunter2", "TOKEN": `{"a": 1}`, "JSON": `{"a": 2}`}}
+	results, errs := ev.EvaluateAll(exprs, ctx)
+	for i, want := range []string{"xhunter2", "x***", "y***", "yhunter2", "1", "1", "2", "2"} {
+		if errs[i] != nil || results[i].CoerceString() != want {
+			t.Errorf("EvaluateAll() of %q = %v, %v, want %v", exprs[i], results[i], errs[i], want)
+		}
+	}
+}
+
+func TestEvaluation_DoesNotMemoizeCustomFunctions(t *testing.T) {
+	calls := 0
+	if err := RegisterFunction("counter", 0, func(args ...*EvaluationResult) (*EvaluationResult, error) {
+		calls++
+		return &EvaluationResult{float64(calls), &actionlint.NumberType{}}, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { delete(functions, "counter") })
+
+	got, err := Evaluate("counter() != counter()", nil)
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+
+	if got.Value != true || calls != 2 {
+		t.Errorf("Evaluate() = %v with %d calls, want %v with 2 calls", got.Value, calls, true)
+	}
+}
+
+func BenchmarkEvaluate_RepeatedFromJSON(b *testing.B) {
+	ctx := MapContext{
+		"env": ContextData{
+			"MATRIX": `{"include": [{"os": "ubuntu-latest", "node": 14}, {"os": "windows-latest", "node": 16}]}`,
+		},
+	}
+
+	for i := 0; i < b.N; i++ {
+		if _, err := Evaluate("fromJSON(env.MATRIX).include[0].os == 'ubuntu-latest' && fromJSON(env.MATRIX).include[1].node == 16", ctx); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkEvaluate_LargeContext reads a property of a context with many keys. Values are typed
+// without looking at their elements, so the size of the context does not matter.
+func BenchmarkEvaluate_LargeContext(b *testing.B) {
+	pr := ContextData{"n": float64(1)}
+	for i := 0; i < 20000; i++ {
+		pr[fmt.Sprintf("key%d", i)] = ContextData{"a": []interface{}{float64(i), "x"}}
+	}
+
+	ctx := MapContext{"github": ContextData{"event": ContextData{"pr": pr}}}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := Evaluate("github.event.pr.n == 1", ctx); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
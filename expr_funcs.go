@@ -1,104 +1,817 @@
 package expr
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 
+	errs "github.com/pkg/errors"
 	"github.com/rhysd/actionlint"
 )
 
+// Function is the implementation of an expression function. It is called with the evaluated arguments
+// once their number has been validated.
+type Function func(args ...*EvaluationResult) (*EvaluationResult, error)
+
 type funcDef struct {
+	// name is the canonical spelling of the function name, used in suggestions.
+	name string
+
 	// argsCount is the number of required arguments. Positive values have to be matched exactly,
 	// negative values indicate the abs(minimum) number of arguments required
 	argsCount int
 
-	call func(args ...*EvaluationResult) *EvaluationResult
+	// maxArgs is the maximum number of arguments accepted by a function with a variable number of
+	// arguments. Zero means there is no upper limit.
+	maxArgs int
+
+	// returns is the type of the results of the function, used by InferType. If nil, the type is not
+	// known in advance.
+	returns actionlint.ExprType
+
+	// volatile functions may return different results for the same arguments, their results are never
+	// memoized.
+	volatile bool
+
+	call func(ev *Evaluator, args ...*EvaluationResult) (*EvaluationResult, error)
 }
 
+// checkArgs validates that the function can be called with the given number of arguments.
+func (f funcDef) checkArgs(count int) error {
+	if f.argsCount >= 0 {
+		if f.argsCount != count {
+			return fmt.Errorf("invalid number of arguments. expected %d, got %d", f.argsCount, count)
+		}
+
+		return nil
+	}
+
+	if min := -f.argsCount; min > count {
+		return fmt.Errorf("invalid number of arguments. expected at least %d, got %d", min, count)
+	}
+
+	if f.maxArgs > 0 && f.maxArgs < count {
+		return fmt.Errorf("invalid number of arguments. expected at most %d, got %d", f.maxArgs, count)
+	}
+
+	return nil
+}
+
+// functionsMu guards functions, so expressions can be evaluated while functions are registered.
+var functionsMu sync.RWMutex
+
 var functions map[string]funcDef = map[string]funcDef{
-	"startswith": {
+	"contains": {
+		name:      "contains",
 		argsCount: 2,
-		call: func(args ...*EvaluationResult) *EvaluationResult {
-			// TODO: Check types of parameters
-			left := args[0]
-			if !left.Primitive() {
-				return &EvaluationResult{false, &actionlint.BoolType{}}
+		returns:   &actionlint.BoolType{},
+		call: func(ev *Evaluator, args ...*EvaluationResult) (*EvaluationResult, error) {
+			search := args[0]
+			item := args[1]
+
+			// Arrays contain an item if any element is equal according to the rules of ==
+			if ar, ok := search.Value.([]interface{}); ok {
+				// Only primitive items can be found
+				if !item.Primitive() {
+					return NewBool(false), nil
+				}
+
+				for _, a := range ar {
+					if item.equals(&EvaluationResult{a, getExprType(a)}, ev.CaseSensitiveStrings) {
+						return NewBool(true), nil
+					}
+				}
+
+				return NewBool(false), nil
 			}
 
-			right := args[1]
-			if !left.Primitive() {
-				return &EvaluationResult{false, &actionlint.BoolType{}}
+			// Objects cannot be searched
+			if !search.Primitive() {
+				return NewBool(false), nil
 			}
 
-			ls := left.CoerceString()
-			rs := right.CoerceString()
+			ss := search.unmaskedString()
+			is := item.unmaskedString()
 
-			// Expression string comparisons are string insensitive
-			return &EvaluationResult{strings.HasPrefix(strings.ToLower(ls), strings.ToLower(rs)), &actionlint.BoolType{}}
+			// Expression string comparisons are case-insensitive, unless CaseSensitiveStrings is set
+			return NewBool(strings.Contains(ev.foldCase(ss), ev.foldCase(is))), nil
 		},
 	},
 
-	"endswith": {
+	"startswith": {
+		name:      "startsWith",
 		argsCount: 2,
-		call: func(args ...*EvaluationResult) *EvaluationResult {
-			// TODO: Check types of parameters
+		returns:   &actionlint.BoolType{},
+		call: func(ev *Evaluator, args ...*EvaluationResult) (*EvaluationResult, error) {
+			// Arguments are always compared as strings. Unlike contains(), arrays are not searched but
+			// converted to a string like every other value.
 			left := args[0]
-			if !left.Primitive() {
-				return &EvaluationResult{false, &actionlint.BoolType{}}
-			}
+			right := args[1]
+
+			ls := left.unmaskedString()
+			rs := right.unmaskedString()
+
+			// Expression string comparisons are case-insensitive, unless CaseSensitiveStrings is set
+			return NewBool(strings.HasPrefix(ev.foldCase(ls), ev.foldCase(rs))), nil
+		},
+	},
 
+	"endswith": {
+		name:      "endsWith",
+		argsCount: 2,
+		returns:   &actionlint.BoolType{},
+		call: func(ev *Evaluator, args ...*EvaluationResult) (*EvaluationResult, error) {
+			// Arguments are always compared as strings. Unlike contains(), arrays are not searched but
+			// converted to a string like every other value.
+			left := args[0]
 			right := args[1]
-			if !left.Primitive() {
-				return &EvaluationResult{false, &actionlint.BoolType{}}
-			}
 
-			ls := left.CoerceString()
-			rs := right.CoerceString()
+			ls := left.unmaskedString()
+			rs := right.unmaskedString()
 
-			// Expression string comparisons are string insensitive
-			return &EvaluationResult{strings.HasSuffix(strings.ToLower(ls), strings.ToLower(rs)), &actionlint.BoolType{}}
+			// Expression string comparisons are case-insensitive, unless CaseSensitiveStrings is set
+			return NewBool(strings.HasSuffix(ev.foldCase(ls), ev.foldCase(rs))), nil
 		},
 	},
 
 	"join": {
+		name:      "join",
 		argsCount: -1,
-		call: func(args ...*EvaluationResult) *EvaluationResult {
-			separator := ","
+		maxArgs:   2,
+		returns:   &actionlint.StringType{},
+		call: func(ev *Evaluator, args ...*EvaluationResult) (*EvaluationResult, error) {
+			separator, maskedSeparator := ",", ","
 
-			// String
+			// Strings are returned unchanged, keeping masked secrets masked. Other primitives, including
+			// null, are converted to a string.
 			if args[0].Primitive() {
-				return args[0]
+				if _, ok := args[0].Type.(*actionlint.StringType); ok {
+					return args[0], nil
+				}
+
+				return NewString(args[0].CoerceString()), nil
 			}
 
+			// Items and the separator are joined with their actual values, and again with secrets masked if
+			// any of them is a secret
+			secret := false
 			if len(args) > 1 {
-				separator = args[1].CoerceString()
+				separator, maskedSeparator = args[1].unmaskedString(), args[1].CoerceString()
+				secret = args[1].isSecret()
 			}
 
-			ar := args[0].Value.([]interface{})
+			ar, ok := args[0].Value.([]interface{})
+			if !ok {
+				// Only arrays are joined, everything else is converted to a string
+				return NewString(args[0].CoerceString()), nil
+			}
 
 			v := make([]string, len(ar))
+			n := 0
 			for i, a := range ar {
-				ar := &EvaluationResult{a, getExprType(a)}
-				v[i] = ar.CoerceString()
+				if _, ok := maskedValue(a); ok {
+					v[i] = toString(a)
+					secret = true
+				} else {
+					v[i] = coerceValueString(a)
+				}
+				n += len(v[i])
+				if i > 0 {
+					n += len(separator)
+				}
+			}
+
+			// Checked before joining, so overly long results are never built
+			if err := checkStringLength(n, ev.MaxStringLength); err != nil {
+				return nil, err
+			}
+
+			joined := strings.Join(v, separator)
+			masked := joined
+			if secret {
+				for i, a := range ar {
+					v[i] = coerceValueString(a)
+				}
+
+				masked = strings.Join(v, maskedSeparator)
+			}
+
+			return newStringResult(joined, masked), nil
+		},
+	},
+
+	"format": {
+		name:      "format",
+		argsCount: -1,
+		returns:   &actionlint.StringType{},
+		call: func(ev *Evaluator, args ...*EvaluationResult) (*EvaluationResult, error) {
+			if _, ok := args[0].Type.(*actionlint.StringType); !ok && ev.Strict {
+				return nil, fmt.Errorf("strict mode: format string must be a string, got %s", kindName(args[0].Type))
+			}
+
+			format := args[0].unmaskedString()
+
+			s, err := formatString(format, args[1:], ev.MaxStringLength)
+			if err != nil {
+				return nil, err
 			}
 
-			return &EvaluationResult{strings.Join(v, separator), &actionlint.StringType{}}
+			// Arguments are formatted with their actual values, and again with secrets masked if any of them
+			// is a secret
+			secret := false
+			maskedArgs := make([]*EvaluationResult, len(args)-1)
+			for i, arg := range args {
+				secret = secret || arg.isSecret()
+				if i > 0 {
+					maskedArgs[i-1] = arg
+					if arg.isSecret() {
+						maskedArgs[i-1] = NewString(arg.CoerceString())
+					}
+				}
+			}
+
+			masked := s
+			if secret {
+				// A masked format string may not be valid anymore
+				if masked, err = formatString(args[0].CoerceString(), maskedArgs, 0); err != nil {
+					masked = secretMask
+				}
+			}
+
+			return newStringResult(s, masked), nil
+		},
+	},
+
+	"tojson": {
+		name:      "toJSON",
+		argsCount: 1,
+		returns:   &actionlint.StringType{},
+		call: func(ev *Evaluator, args ...*EvaluationResult) (*EvaluationResult, error) {
+			// Secrets are serialized with their actual values, and again masked
+			v := args[0].Value
+			secret := containsSecret(v)
+			if secret {
+				v = unmaskSecrets(v)
+			}
+
+			s, err := toJSON(v)
+			if err != nil {
+				return nil, errs.Wrap(err, "could not serialize value to JSON")
+			}
+
+			if err := checkStringLength(len(s), ev.MaxStringLength); err != nil {
+				return nil, err
+			}
+
+			masked := s
+			if secret {
+				if masked, err = toJSON(args[0].Value); err != nil {
+					return nil, errs.Wrap(err, "could not serialize value to JSON")
+				}
+			}
+
+			return newStringResult(s, masked), nil
+		},
+	},
+
+	"hashfiles": {
+		name:      "hashFiles",
+		argsCount: -1,
+		returns:   &actionlint.StringType{},
+		call: func(ev *Evaluator, args ...*EvaluationResult) (*EvaluationResult, error) {
+			patterns := make([]string, len(args))
+			for i, arg := range args {
+				patterns[i] = arg.unmaskedString()
+			}
+
+			if ev.Workspace == "" {
+				return nil, errors.New("no workspace to resolve files in, Evaluator.Workspace is not set")
+			}
+
+			hash, err := hashFiles(ev.Workspace, patterns)
+			if err != nil {
+				return nil, errs.Wrap(err, "could not hash files")
+			}
+
+			return NewString(hash), nil
 		},
 	},
 
 	"fromjson": {
+		name:      "fromJSON",
 		argsCount: 1,
-		call: func(args ...*EvaluationResult) *EvaluationResult {
+		call: func(ev *Evaluator, args ...*EvaluationResult) (*EvaluationResult, error) {
 			input := args[0]
-			inputStr := input.CoerceString()
+			inputStr := input.unmaskedString()
 
-			var v ContextData
-			if err := json.Unmarshal([]byte(inputStr), &v); err != nil {
-				// Ignore
-				v = ContextData{}
+			// Errors about the actual value of a secret, like an unexpected character, would reveal parts
+			// of it
+			secret := input.isSecret()
+			parseError := func(err error) error {
+				if secret {
+					err = errors.New("masked secret is not valid JSON")
+				}
+
+				return errs.Wrap(err, "could not parse JSON")
+			}
+
+			if ev.MaxJSONSize > 0 && len(inputStr) > ev.MaxJSONSize {
+				return nil, fmt.Errorf("JSON input of %d bytes exceeds the maximum size of %d bytes", len(inputStr), ev.MaxJSONSize)
 			}
 
-			return &EvaluationResult{v, &actionlint.ObjectType{}}
+			if ev.RejectDuplicateJSONKeys {
+				if err := checkDuplicateKeys(inputStr); err != nil {
+					return nil, parseError(err)
+				}
+			}
+
+			// Numbers are decoded into float64 like on GitHub. Integers up to 2^53 are kept exactly and
+			// converted back to strings without exponent, larger ones lose precision.
+			var v interface{}
+			if ev.PreserveNumberPrecision {
+				if err := decodeJSONNumbers(inputStr, &v); err != nil {
+					return nil, parseError(err)
+				}
+			} else if err := json.Unmarshal([]byte(inputStr), &v); err != nil {
+				return nil, parseError(err)
+			}
+
+			// Every array in the result is a new reference, including empty ones
+			v = distinctEmptyArrays(v)
+
+			// Strings decoded from a secret are secrets as well
+			if secret {
+				v = maskSecrets(v)
+			}
+
+			return &EvaluationResult{v, getDeepExprType(v)}, nil
+		},
+	},
+
+	//
+	// Status check functions
+	//
+	"success": {
+		name:      "success",
+		argsCount: 0,
+		returns:   &actionlint.BoolType{},
+		call: func(ev *Evaluator, args ...*EvaluationResult) (*EvaluationResult, error) {
+			return NewBool(ev.Status == StatusSuccess), nil
+		},
+	},
+
+	"always": {
+		name:      "always",
+		argsCount: 0,
+		returns:   &actionlint.BoolType{},
+		call: func(ev *Evaluator, args ...*EvaluationResult) (*EvaluationResult, error) {
+			return NewBool(true), nil
+		},
+	},
+
+	"cancelled": {
+		name:      "cancelled",
+		argsCount: 0,
+		returns:   &actionlint.BoolType{},
+		call: func(ev *Evaluator, args ...*EvaluationResult) (*EvaluationResult, error) {
+			return NewBool(ev.Status == StatusCancelled), nil
 		},
 	},
+
+	"failure": {
+		name:      "failure",
+		argsCount: 0,
+		returns:   &actionlint.BoolType{},
+		call: func(ev *Evaluator, args ...*EvaluationResult) (*EvaluationResult, error) {
+			return NewBool(ev.Status == StatusFailure), nil
+		},
+	},
+}
+
+// foldCase returns s in the form strings are compared in by functions like contains(). See
+// equalIgnoreCase for how the case is ignored.
+func (e *Evaluator) foldCase(s string) string {
+	if e.CaseSensitiveStrings {
+		return s
+	}
+
+	return strings.ToUpper(s)
+}
+
+// RegisterFunction makes a custom function available to all expressions. Function names are
+// case-insensitive. argsCount follows the same convention as the built-in functions: positive values
+// have to be matched exactly, negative values indicate the abs(minimum) number of arguments.
+//
+// Registering a function with the name of an existing function, including the built-in ones, fails.
+// Use OverrideFunction to replace an existing function.
+//
+// Functions can be registered at any time, also while expressions are evaluated concurrently.
+// Evaluations that are already running may or may not see the new function.
+func RegisterFunction(name string, argsCount int, call Function) error {
+	functionsMu.Lock()
+	defer functionsMu.Unlock()
+
+	key := strings.ToLower(name)
+	if _, ok := functions[key]; ok {
+		return fmt.Errorf("function %s is already registered", name)
+	}
+
+	functions[key] = newFuncDef(name, argsCount, call)
+
+	return nil
+}
+
+// OverrideFunction registers a custom function like RegisterFunction, replacing any existing function
+// with the same name.
+func OverrideFunction(name string, argsCount int, call Function) {
+	functionsMu.Lock()
+	defer functionsMu.Unlock()
+
+	functions[strings.ToLower(name)] = newFuncDef(name, argsCount, call)
+}
+
+// FunctionInfo describes a function available to expressions.
+type FunctionInfo struct {
+	// Name is the canonical spelling of the function name, like `startsWith`.
+	Name string
+
+	// MinArgs is the minimum number of arguments.
+	MinArgs int
+
+	// MaxArgs is the maximum number of arguments, or -1 if the function is variadic.
+	MaxArgs int
+
+	// Variadic is set if there is no maximum number of arguments.
+	Variadic bool
+}
+
+// Functions returns the built-in and registered functions, sorted by name. Use Evaluator.Functions for
+// the functions available to a specific evaluator.
+func Functions() []FunctionInfo {
+	functionsMu.RLock()
+	defer functionsMu.RUnlock()
+
+	return functionInfos(functions)
+}
+
+// Functions returns the functions this evaluator can call, sorted by name: the built-in and registered
+// functions, those given to WithFunctions, and the extended functions if ExtendedFunctions is set.
+// DisabledFunctions are left out.
+func (e *Evaluator) Functions() []FunctionInfo {
+	return functionInfos(e.availableFunctions())
+}
+
+func functionInfos(funcs map[string]funcDef) []FunctionInfo {
+	infos := make([]FunctionInfo, 0, len(funcs))
+	for _, f := range funcs {
+		infos = append(infos, f.info())
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return strings.ToLower(infos[i].Name) < strings.ToLower(infos[j].Name)
+	})
+
+	return infos
+}
+
+func (f funcDef) info() FunctionInfo {
+	if f.argsCount >= 0 {
+		return FunctionInfo{Name: f.name, MinArgs: f.argsCount, MaxArgs: f.argsCount}
+	}
+
+	if f.maxArgs > 0 {
+		return FunctionInfo{Name: f.name, MinArgs: -f.argsCount, MaxArgs: f.maxArgs}
+	}
+
+	return FunctionInfo{Name: f.name, MinArgs: -f.argsCount, MaxArgs: -1, Variadic: true}
+}
+
+// FunctionDefinition is a function passed to WithFunctions. ArgsCount follows the same convention as
+// for RegisterFunction.
+type FunctionDefinition struct {
+	ArgsCount int
+	Call      Function
+}
+
+// WithFunctions makes the given functions available to a single Evaluator, without registering them
+// for all expressions. Function names are case-insensitive. The built-in and registered functions
+// remain available, unless a function with the same name is given.
+func WithFunctions(funcs map[string]FunctionDefinition) Option {
+	return func(e *Evaluator) {
+		if e.functions == nil {
+			e.functions = make(map[string]funcDef, len(funcs))
+		}
+
+		for name, f := range funcs {
+			e.functions[strings.ToLower(name)] = newFuncDef(name, f.ArgsCount, f.Call)
+		}
+	}
+}
+
+// lookupFunction returns the function available to this evaluator under the given lowercase name.
+func (e *Evaluator) lookupFunction(key string) (funcDef, bool) {
+	if f, ok := e.functions[key]; ok {
+		return f, true
+	}
+
+	if f, ok := lookupFunction(key); ok {
+		return f, true
+	}
+
+	if e.ExtendedFunctions {
+		f, ok := extendedFunctions[key]
+		return f, ok
+	}
+
+	return funcDef{}, false
+}
+
+// disabledFunction reports whether the function with the given lowercase name is one of
+// DisabledFunctions.
+func (e *Evaluator) disabledFunction(key string) bool {
+	for _, name := range e.DisabledFunctions {
+		if strings.EqualFold(name, key) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// availableFunctions returns the functions this evaluator can call, keyed by lowercase name: its own
+// functions, the built-in and registered ones, and the extended ones if enabled, without
+// DisabledFunctions.
+func (e *Evaluator) availableFunctions() map[string]funcDef {
+	available := map[string]funcDef{}
+	if e.ExtendedFunctions {
+		for key, f := range extendedFunctions {
+			available[key] = f
+		}
+	}
+
+	functionsMu.RLock()
+	for key, f := range functions {
+		available[key] = f
+	}
+	functionsMu.RUnlock()
+
+	for key, f := range e.functions {
+		available[key] = f
+	}
+
+	for key := range available {
+		if e.disabledFunction(key) {
+			delete(available, key)
+		}
+	}
+
+	return available
+}
+
+// lookupFunction returns the function registered under the given lowercase name.
+func lookupFunction(key string) (funcDef, bool) {
+	functionsMu.RLock()
+	defer functionsMu.RUnlock()
+
+	f, ok := functions[key]
+	return f, ok
+}
+
+func newFuncDef(name string, argsCount int, call Function) funcDef {
+	return funcDef{
+		name:      name,
+		argsCount: argsCount,
+		// Nothing is known about custom functions, always call them
+		volatile: true,
+		call: func(ev *Evaluator, args ...*EvaluationResult) (*EvaluationResult, error) {
+			return call(args...)
+		},
+	}
+}
+
+// suggestFunction returns the canonical name of the function available to this evaluator closest to
+// the given lowercase name, or an empty string if no function is similar enough.
+func (e *Evaluator) suggestFunction(name string) string {
+	// Allow roughly one typo for every three characters
+	best, bestDist := "", len(name)/3+1
+
+	for key, f := range e.availableFunctions() {
+		d := editDistance(name, key)
+		if d < bestDist || (d == bestDist && best != "" && f.name < best) {
+			best, bestDist = f.name, d
+		}
+	}
+
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	ar, br := []rune(a), []rune(b)
+
+	prev := make([]int, len(br)+1)
+	cur := make([]int, len(br)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ar); i++ {
+		cur[0] = i
+		for j := 1; j <= len(br); j++ {
+			cost := 1
+			if ar[i-1] == br[j-1] {
+				cost = 0
+			}
+
+			// Deletion, insertion, or substitution
+			cur[j] = prev[j] + 1
+			if c := cur[j-1] + 1; c < cur[j] {
+				cur[j] = c
+			}
+			if c := prev[j-1] + cost; c < cur[j] {
+				cur[j] = c
+			}
+		}
+
+		prev, cur = cur, prev
+	}
+
+	return prev[len(br)]
+}
+
+// toJSON serializes the given value like GitHub does, pretty-printed with two spaces of indentation.
+// Object keys are sorted, as the order of keys in the original input is not retained. GitHub keeps
+// that order instead, but the output is stable for the same value.
+func toJSON(v interface{}) (string, error) {
+	var buf bytes.Buffer
+
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return "", err
+	}
+
+	// Encode always terminates the value with a newline
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// decodeJSONNumbers decodes data like json.Unmarshal, but keeps numbers as json.Number.
+func decodeJSONNumbers(data string, v *interface{}) error {
+	dec := json.NewDecoder(strings.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+
+		return err
+	}
+
+	// Like json.Unmarshal, only whitespace may follow the value
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("invalid character after top-level value")
+	}
+
+	return nil
+}
+
+// checkDuplicateKeys returns an error if an object in the given JSON document contains the same key
+// more than once. Keys are compared case-insensitively, like properties are accessed.
+func checkDuplicateKeys(data string) error {
+	dec := json.NewDecoder(strings.NewReader(data))
+
+	// Keys of the objects currently being decoded, nil for arrays
+	var stack []map[string]bool
+	// Whether the next token in the innermost object is a key
+	expectKey := false
+
+	for {
+		t, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if expectKey {
+			if d, ok := t.(json.Delim); !ok || d != '}' {
+				key := t.(string)
+				keys := stack[len(stack)-1]
+				if keys[strings.ToLower(key)] {
+					return fmt.Errorf("duplicate key %q", key)
+				}
+				keys[strings.ToLower(key)] = true
+
+				expectKey = false
+				continue
+			}
+		}
+
+		switch t {
+		case json.Delim('{'):
+			stack = append(stack, map[string]bool{})
+		case json.Delim('['):
+			stack = append(stack, nil)
+		case json.Delim('}'), json.Delim(']'):
+			stack = stack[:len(stack)-1]
+		}
+
+		// After a value or an opening brace, an object continues with a key
+		expectKey = len(stack) > 0 && stack[len(stack)-1] != nil
+	}
+}
+
+// checkStringLength returns an error if a string of n bytes exceeds max. If max is zero, the length
+// is not limited.
+func checkStringLength(n, max int) error {
+	if max > 0 && n > max {
+		return fmt.Errorf("string of %d bytes exceeds the maximum length of %d bytes", n, max)
+	}
+
+	return nil
+}
+
+// formatString replaces `{N}` placeholders in format with the coerced string value of the N-th
+// argument. `{{` and `}}` are escapes for literal braces. It fails as soon as the result would be
+// longer than maxLength bytes, unless maxLength is zero.
+func formatString(format string, args []*EvaluationResult, maxLength int) (string, error) {
+	var sb strings.Builder
+
+	// write appends s to the result, unless it would become too long
+	write := func(s string) error {
+		if err := checkStringLength(sb.Len()+len(s), maxLength); err != nil {
+			return err
+		}
+
+		sb.WriteString(s)
+		return nil
+	}
+
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+
+		switch c {
+		case '{':
+			if i+1 < len(format) && format[i+1] == '{' {
+				// Escaped brace
+				if err := write("{"); err != nil {
+					return "", err
+				}
+				i++
+				continue
+			}
+
+			end := strings.IndexByte(format[i:], '}')
+			if end == -1 {
+				return "", fmt.Errorf("invalid format string, unclosed placeholder at %d: %s", i, format)
+			}
+
+			// Only digits are allowed, strconv.Atoi would also accept signs
+			placeholder := format[i+1 : i+end]
+			if placeholder == "" || !strAll(placeholder, func(r rune) bool { return r >= '0' && r <= '9' }) {
+				return "", fmt.Errorf("invalid format string, invalid placeholder at %d: %s", i, format)
+			}
+
+			idx, err := strconv.Atoi(placeholder)
+			if err != nil {
+				return "", fmt.Errorf("invalid format string, invalid placeholder at %d: %s", i, format)
+			}
+
+			if idx >= len(args) {
+				return "", fmt.Errorf("invalid format string, index %d out of range for %d argument(s): %s", idx, len(args), format)
+			}
+
+			if err := write(args[idx].unmaskedString()); err != nil {
+				return "", err
+			}
+			i += end
+
+		case '}':
+			if i+1 < len(format) && format[i+1] == '}' {
+				// Escaped brace
+				if err := write("}"); err != nil {
+					return "", err
+				}
+				i++
+				continue
+			}
+
+			return "", fmt.Errorf("invalid format string, unexpected '}' at %d: %s", i, format)
+
+		default:
+			if err := write(format[i : i+1]); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	return sb.String(), nil
 }
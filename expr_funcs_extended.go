@@ -0,0 +1,51 @@
+package expr
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/rhysd/actionlint"
+)
+
+// extendedFunctions are functions not supported by GitHub. They are only available if
+// Evaluator.ExtendedFunctions is enabled, and registered functions take precedence over them.
+var extendedFunctions map[string]funcDef = map[string]funcDef{
+	"length": {
+		name:      "length",
+		argsCount: 1,
+		returns:   &actionlint.NumberType{},
+		call: func(ev *Evaluator, args ...*EvaluationResult) (*EvaluationResult, error) {
+			switch args[0].Type.(type) {
+			case *actionlint.StringType:
+				// Secrets have the length of their masked value, their actual length would reveal it
+				return NewNumber(float64(utf8.RuneCountInString(args[0].CoerceString()))), nil
+
+			case *actionlint.ArrayType:
+				if values, ok := args[0].Value.([]interface{}); ok {
+					return NewNumber(float64(len(values))), nil
+				}
+			}
+
+			return nil, fmt.Errorf("argument must be a string or an array, got %s", kindName(args[0].Type))
+		},
+	},
+
+	"trim": {
+		name:      "trim",
+		argsCount: 1,
+		returns:   &actionlint.StringType{},
+		call: func(ev *Evaluator, args ...*EvaluationResult) (*EvaluationResult, error) {
+			// Trimming a secret results in a secret
+			switch s := args[0].Value.(type) {
+			case secretString:
+				return &EvaluationResult{secretString(strings.TrimSpace(string(s))), &actionlint.StringType{}}, nil
+
+			case maskedString:
+				return newStringResult(strings.TrimSpace(s.value), strings.TrimSpace(s.masked)), nil
+			}
+
+			return NewString(strings.TrimSpace(args[0].CoerceString())), nil
+		},
+	},
+}
@@ -0,0 +1,106 @@
+package expr
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEvaluator_ExtendedFunctions(t *testing.T) {
+	ctx := MapContext{
+		"inputs": ContextData{
+			"name": "  release  ",
+			"tags": []interface{}{"a", "b"},
+		},
+	}
+
+	tests := []struct {
+		input string
+		want  interface{}
+	}{
+		{"length(fromJSON('[1,2,3]'))", float64(3)},
+		{"length(fromJSON('[]'))", float64(0)},
+		{"length('abc')", float64(3)},
+		{"length('')", float64(0)},
+		{"length('äöü')", float64(3)},
+		{"length(inputs.tags) > 1", true},
+		{"length(inputs.tags.*)", float64(2)},
+		{"trim(inputs.name)", "release"},
+		{"trim(' \t a b \n')", "a b"},
+		{"length(trim(inputs.name))", float64(7)},
+		{"trim(1)", "1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := (&Evaluator{ExtendedFunctions: true}).Evaluate(tt.input, ctx)
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+
+			if got.Value != tt.want {
+				t.Errorf("Evaluate() = %v, want %v", got.Value, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluator_ExtendedFunctions_Disabled(t *testing.T) {
+	for _, input := range []string{"length('abc')", "trim('abc')"} {
+		t.Run(input, func(t *testing.T) {
+			_, err := Evaluate(input, nil)
+
+			var ferr *UndefinedFunctionError
+			if !errors.As(err, &ferr) {
+				t.Errorf("Evaluate() error = %v, want UndefinedFunctionError", err)
+			}
+		})
+	}
+}
+
+func TestEvaluator_ExtendedFunctions_Invalid(t *testing.T) {
+	for _, input := range []string{"length(1)", "length(null)", "length(fromJSON('{}'))"} {
+		t.Run(input, func(t *testing.T) {
+			if got, err := (&Evaluator{ExtendedFunctions: true}).Evaluate(input, nil); err == nil {
+				t.Errorf("Evaluate() = %v, want error", got.Value)
+			}
+		})
+	}
+}
+
+func TestEvaluator_ExtendedFunctions_Secrets(t *testing.T) {
+	ev := &Evaluator{ExtendedFunctions: true, MaskSecrets: true}
+	ctx := MapContext{"secrets": ContextData{"TOKEN": " s3cr3t "}}
+
+	got, err := ev.Evaluate("format('{0}', trim(secrets.TOKEN))", ctx)
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+
+	if got.CoerceString() != "***" {
+		t.Errorf("Evaluate() = %v, want ***", got)
+	}
+
+	got, err = ev.Evaluate("trim(secrets.TOKEN) == 's3cr3t'", ctx)
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+
+	if got.Value != true {
+		t.Errorf("Evaluate() = %v, want true", got.Value)
+	}
+
+	// The length of the actual value would reveal it
+	for input, want := range map[string]float64{
+		"length(secrets.TOKEN)":                  3,
+		"length(trim(secrets.TOKEN))":            3,
+		"length(format('a-{0}', secrets.TOKEN))": 5,
+	} {
+		got, err = ev.Evaluate(input, ctx)
+		if err != nil {
+			t.Fatalf("Evaluate() error = %v", err)
+		}
+
+		if got.Value != want {
+			t.Errorf("Evaluate(%q) = %v, want %v", input, got.Value, want)
+		}
+	}
+}
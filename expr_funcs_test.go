@@ -0,0 +1,1456 @@
+package expr
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/rhysd/actionlint"
+)
+
+func Test_formatString(t *testing.T) {
+	args := []*EvaluationResult{
+		{"a", &actionlint.StringType{}},
+		{float64(1), &actionlint.NumberType{}},
+	}
+
+	tests := []struct {
+		name   string
+		format string
+		want   string
+	}{
+		{"no placeholders", "test", "test"},
+		{"placeholders", "{0}-{1}", "a-1"},
+		{"escaped open", "{{", "{"},
+		{"escaped close", "}}", "}"},
+		{"escaped placeholder", "{{0}}", "{0}"},
+		{"escaped and placeholder", "{{{0}}}", "{a}"},
+		{"repeated placeholder", "{0}{0}{1}", "aa1"},
+		{"reversed placeholders", "{1}-{0}-{1}", "1-a-1"},
+		{"unused argument", "{1}", "1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := formatString(tt.format, args, 0)
+			if err != nil {
+				t.Fatalf("formatString() error = %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("formatString() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_formatString_Invalid(t *testing.T) {
+	args := []*EvaluationResult{
+		{"a", &actionlint.StringType{}},
+		{"b", &actionlint.StringType{}},
+	}
+
+	tests := []struct {
+		name   string
+		format string
+	}{
+		{"out of range", "{5}"},
+		{"unclosed", "{0"},
+		{"not a number", "{a}"},
+		{"negative", "{-1}"},
+		{"negative zero", "{-0}"},
+		{"positive zero", "{+0}"},
+		{"positive", "{+1}"},
+		{"empty", "{}"},
+		{"space", "{ 0}"},
+		{"too large", "{99999999999999999999}"},
+		{"unexpected close", "}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := formatString(tt.format, args, 0); err == nil {
+				t.Errorf("formatString() did not fail for %v", tt.format)
+			}
+		})
+	}
+}
+
+func TestEvaluate_Format(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"format('{0}{0}{1}', 'a', 'b')", "aab"},
+		{"format('{2}', 'a', 'b', 'c')", "c"},
+		{"format('{2}{0}', 'a', 'b', 'c')", "ca"},
+		{"format('{1}{1}{1}', 'a', 'b')", "bbb"},
+		{"format('no placeholders', 'a')", "no placeholders"},
+
+		// null is an empty string, zero is printed as a number
+		{"format('{0}-{1}', null, 0)", "-0"},
+		{"format('{1}{0}', null, null)", ""},
+		{"format('[{0}]', 0)", "[0]"},
+		{"format('{0}', fromJSON('-0'))", "0"},
+		{"format('{0}', fromJSON('null'))", ""},
+		{"format('{0}{1}', github.missing, false)", "false"},
+
+		// Numbers are printed with at most 15 significant digits, like GitHub does
+		{"format('{0}', fromJSON('0.3333333333333333'))", "0.333333333333333"},
+		{"format('{0}', fromJSON('0.6666666666666666'))", "0.666666666666667"},
+		{"format('{0}', fromJSON('2.50'))", "2.5"},
+		{"format('{0}', fromJSON('2.5'))", "2.5"},
+		{"format('{0}', 0.30000000000000004)", "0.3"},
+		{"format('{0}', fromJSON('1e21'))", "1E+21"},
+		{"format('{0}', fromJSON('0.0000001'))", "1E-07"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := Evaluate(tt.input, nil)
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+
+			if got.Value != tt.want {
+				t.Errorf("Evaluate() = %v, want %v", got.Value, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluate_Format_Composite(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"format('{0}', fromJSON('{}'))", "Object"},
+		{"format('{0}', fromJSON('{\"a\": 1}'))", "Object"},
+		{"format('{0}', fromJSON('[]'))", "Array"},
+		{"format('{0}|{1}', fromJSON('[[1]]'), fromJSON('[1]').*)", "Array|Array"},
+		{"join(fromJSON('[{}, [], 1]'), ' ')", "Object Array 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := Evaluate(tt.input, nil)
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+
+			if got.Value != tt.want {
+				t.Errorf("Evaluate() = %v, want %v", got.Value, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluate_Format_NonFiniteNumbers(t *testing.T) {
+	// There are no arithmetic operators, but contexts can hold numbers that are not finite
+	ctx := MapContext{"github": ContextData{"inf": math.Inf(1), "ninf": math.Inf(-1), "nan": math.NaN()}}
+
+	tests := []struct {
+		input string
+		want  interface{}
+	}{
+		{"format('{0}', github.inf)", "Infinity"},
+		{"format('{0}', github.ninf)", "-Infinity"},
+		{"format('{0}', github.nan)", "NaN"},
+		{"join(fromJSON('[1, 2]'), github.inf)", "1Infinity2"},
+		{"github.inf == 'Infinity'", true},
+		{"github.ninf == '-Infinity'", true},
+		{"github.nan == 'NaN'", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := Evaluate(tt.input, ctx)
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+
+			if got.Value != tt.want {
+				t.Errorf("Evaluate() = %v, want %v", got.Value, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluate_Format_MissingArgument(t *testing.T) {
+	_, err := Evaluate("format('{0}')", nil)
+	if err == nil {
+		t.Fatal("Evaluate() did not fail")
+	}
+
+	if want := "error calling format: invalid format string, index 0 out of range for 0 argument(s): {0}"; err.Error() != want {
+		t.Errorf("Evaluate() error = %v, want %v", err, want)
+	}
+}
+
+func TestEvaluator_StatusFunctions(t *testing.T) {
+	tests := []struct {
+		status Status
+		want   map[string]bool
+	}{
+		{StatusSuccess, map[string]bool{"success()": true, "failure()": false, "cancelled()": false, "always()": true}},
+		{StatusFailure, map[string]bool{"success()": false, "failure()": true, "cancelled()": false, "always()": true}},
+		{StatusCancelled, map[string]bool{"success()": false, "failure()": false, "cancelled()": true, "always()": true}},
+	}
+	for _, tt := range tests {
+		for input, want := range tt.want {
+			t.Run(input, func(t *testing.T) {
+				ev := &Evaluator{Status: tt.status}
+				got, err := ev.Evaluate(input, nil)
+				if err != nil {
+					t.Fatalf("Evaluate() error = %v", err)
+				}
+
+				if got.Value != want {
+					t.Errorf("Evaluate() with status %v = %v, want %v", tt.status, got.Value, want)
+				}
+			})
+		}
+	}
+}
+
+func Test_startsWithEndsWith_Coercion(t *testing.T) {
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{"startsWith(fromJSON('123'), '1')", true},
+		{"startsWith(123, 12)", true},
+		{"startsWith(12.5, '12.')", true},
+		{"startsWith(true, 'TR')", true},
+		{"startsWith(false, 'tr')", false},
+		{"startsWith(null, '')", true},
+		{"startsWith(null, 'n')", false},
+		{"startsWith('abc', null)", true},
+		{"endsWith(fromJSON('123'), '3')", true},
+		{"endsWith(123, 23)", true},
+		{"endsWith(true, 'uE')", true},
+		{"endsWith(null, '')", true},
+		{"endsWith(null, 'l')", false},
+		{"endsWith('abc', false)", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := Evaluate(tt.input, nil)
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+
+			if got.Value != tt.want {
+				t.Errorf("Evaluate() = %v, want %v", got.Value, tt.want)
+			}
+		})
+	}
+}
+
+func Test_startsWithEndsWith_NonPrimitiveRight(t *testing.T) {
+	// Both functions have to treat their right argument the same way
+	rights := []string{"fromJSON('{}')", "fromJSON('{\"a\":1}')", "fromJSON('[]')", "fromJSON('[1]')"}
+	lefts := []string{"''", "'abc'", "fromJSON('{}')", "fromJSON('[1]')"}
+
+	for _, left := range lefts {
+		for _, right := range rights {
+			t.Run(left+", "+right, func(t *testing.T) {
+				l, err := Evaluate(left, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				r, err := Evaluate(right, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				ls, rs := l.CoerceString(), r.CoerceString()
+
+				sw, err := Evaluate("startsWith("+left+", "+right+")", nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if want := strings.HasPrefix(ls, rs); sw.Value != want {
+					t.Errorf("startsWith() = %v, want %v", sw.Value, want)
+				}
+
+				ew, err := Evaluate("endsWith("+left+", "+right+")", nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if want := strings.HasSuffix(ls, rs); ew.Value != want {
+					t.Errorf("endsWith() = %v, want %v", ew.Value, want)
+				}
+			})
+		}
+	}
+}
+
+func TestRegisterFunction(t *testing.T) {
+	t.Cleanup(func() {
+		delete(functions, "semvermajor")
+	})
+
+	err := RegisterFunction("semverMajor", 1, func(args ...*EvaluationResult) (*EvaluationResult, error) {
+		major := strings.SplitN(args[0].CoerceString(), ".", 2)[0]
+		return &EvaluationResult{parseNumber(major), &actionlint.NumberType{}}, nil
+	})
+	if err != nil {
+		t.Fatalf("RegisterFunction() error = %v", err)
+	}
+
+	got, err := Evaluate("semvermajor('3.2.1') == 3", nil)
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+
+	if got.Value != true {
+		t.Errorf("Evaluate() = %v, want %v", got.Value, true)
+	}
+
+	if _, err := Evaluate("semverMajor()", nil); err == nil {
+		t.Errorf("Evaluate() did not fail for invalid number of arguments")
+	}
+}
+
+func TestRegisterFunction_Duplicate(t *testing.T) {
+	call := func(args ...*EvaluationResult) (*EvaluationResult, error) {
+		return &EvaluationResult{true, &actionlint.BoolType{}}, nil
+	}
+
+	if err := RegisterFunction("StartsWith", 2, call); err == nil {
+		t.Errorf("RegisterFunction() did not fail for built-in function")
+	}
+
+	t.Cleanup(func() {
+		delete(functions, "custom")
+	})
+
+	if err := RegisterFunction("custom", 0, call); err != nil {
+		t.Fatalf("RegisterFunction() error = %v", err)
+	}
+
+	if err := RegisterFunction("CUSTOM", 0, call); err == nil {
+		t.Errorf("RegisterFunction() did not fail for duplicate function")
+	}
+}
+
+func TestRegisterFunction_Concurrent(t *testing.T) {
+	const n = 20
+
+	t.Cleanup(func() {
+		for i := 0; i < n; i++ {
+			delete(functions, fmt.Sprintf("concurrent%d", i))
+		}
+	})
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*n)
+
+	for i := 0; i < n; i++ {
+		i := i
+		wg.Add(2)
+
+		go func() {
+			defer wg.Done()
+
+			errs <- RegisterFunction(fmt.Sprintf("concurrent%d", i), 0, func(args ...*EvaluationResult) (*EvaluationResult, error) {
+				return &EvaluationResult{float64(i), &actionlint.NumberType{}}, nil
+			})
+		}()
+
+		go func() {
+			defer wg.Done()
+
+			got, err := Evaluate("startsWith('actionlint', 'action') && format('{0}', 1) == '1'", nil)
+			if err == nil && got.Value != true {
+				err = fmt.Errorf("Evaluate() = %v, want true", got.Value)
+			}
+			errs <- err
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		got, err := Evaluate(fmt.Sprintf("concurrent%d()", i), nil)
+		if err != nil {
+			t.Fatalf("Evaluate() error = %v", err)
+		}
+
+		if got.Value != float64(i) {
+			t.Errorf("Evaluate() = %v, want %v", got.Value, i)
+		}
+	}
+}
+
+func TestOverrideFunction(t *testing.T) {
+	original := functions["always"]
+	t.Cleanup(func() {
+		functions["always"] = original
+	})
+
+	OverrideFunction("Always", 0, func(args ...*EvaluationResult) (*EvaluationResult, error) {
+		return &EvaluationResult{false, &actionlint.BoolType{}}, nil
+	})
+
+	got, err := Evaluate("always()", nil)
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+
+	if got.Value != false {
+		t.Errorf("Evaluate() = %v, want %v", got.Value, false)
+	}
+}
+
+func TestFunctions(t *testing.T) {
+	infos := map[string]FunctionInfo{}
+	for _, info := range Functions() {
+		infos[info.Name] = info
+	}
+
+	want := []FunctionInfo{
+		{"contains", 2, 2, false},
+		{"startsWith", 2, 2, false},
+		{"endsWith", 2, 2, false},
+		{"format", 1, -1, true},
+		{"join", 1, 2, false},
+		{"toJSON", 1, 1, false},
+		{"fromJSON", 1, 1, false},
+		{"hashFiles", 1, -1, true},
+		{"success", 0, 0, false},
+		{"always", 0, 0, false},
+		{"cancelled", 0, 0, false},
+		{"failure", 0, 0, false},
+	}
+	for _, w := range want {
+		if got, ok := infos[w.Name]; !ok || got != w {
+			t.Errorf("Functions() contains %+v, want %+v", got, w)
+		}
+	}
+
+	// Extended functions are not included
+	if _, ok := infos["length"]; ok {
+		t.Errorf("Functions() contains length")
+	}
+
+	got := Functions()
+	for i := 1; i < len(got); i++ {
+		if strings.ToLower(got[i-1].Name) > strings.ToLower(got[i].Name) {
+			t.Errorf("Functions() not sorted: %s before %s", got[i-1].Name, got[i].Name)
+		}
+	}
+}
+
+func TestFunctions_Registered(t *testing.T) {
+	OverrideFunction("infoTest", -2, func(args ...*EvaluationResult) (*EvaluationResult, error) {
+		return NewNull(), nil
+	})
+
+	for _, info := range Functions() {
+		if info.Name == "infoTest" {
+			if want := (FunctionInfo{"infoTest", 2, -1, true}); info != want {
+				t.Errorf("Functions() contains %+v, want %+v", info, want)
+			}
+			return
+		}
+	}
+
+	t.Errorf("Functions() does not contain infoTest")
+}
+
+func TestEvaluator_Functions(t *testing.T) {
+	ev := NewEvaluator(WithFunctions(map[string]FunctionDefinition{
+		"custom":   {-1, nil},
+		"contains": {3, nil},
+	}))
+	ev.ExtendedFunctions = true
+	ev.DisabledFunctions = []string{"HASHFILES", "trim"}
+
+	infos := map[string]FunctionInfo{}
+	for _, info := range ev.Functions() {
+		infos[strings.ToLower(info.Name)] = info
+	}
+
+	want := []FunctionInfo{
+		{"custom", 1, -1, true},
+		{"contains", 3, 3, false},
+		{"format", 1, -1, true},
+		{"length", 1, 1, false},
+	}
+	for _, w := range want {
+		if got, ok := infos[strings.ToLower(w.Name)]; !ok || got != w {
+			t.Errorf("Functions() contains %+v, want %+v", got, w)
+		}
+	}
+
+	for _, name := range []string{"hashfiles", "trim"} {
+		if _, ok := infos[name]; ok {
+			t.Errorf("Functions() contains disabled function %s", name)
+		}
+	}
+
+	got := ev.Functions()
+	for i := 1; i < len(got); i++ {
+		if strings.ToLower(got[i-1].Name) > strings.ToLower(got[i].Name) {
+			t.Errorf("Functions() not sorted: %s before %s", got[i-1].Name, got[i].Name)
+		}
+	}
+
+	// Without options, the same functions as the package-level Functions are available
+	if got, want := (&Evaluator{}).Functions(), Functions(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Functions() = %v, want %v", got, want)
+	}
+}
+
+func TestWithFunctions(t *testing.T) {
+	constant := func(v string) FunctionDefinition {
+		return FunctionDefinition{0, func(args ...*EvaluationResult) (*EvaluationResult, error) {
+			return NewString(v), nil
+		}}
+	}
+
+	a := NewEvaluator(WithFunctions(map[string]FunctionDefinition{"scoped": constant("a")}))
+	b := NewEvaluator(WithFunctions(map[string]FunctionDefinition{
+		"Scoped": constant("b"),
+		"always": {0, func(args ...*EvaluationResult) (*EvaluationResult, error) {
+			return NewBool(false), nil
+		}},
+		"double": {1, func(args ...*EvaluationResult) (*EvaluationResult, error) {
+			return NewNumber(args[0].CoerceNumber() * 2), nil
+		}},
+	}))
+
+	tests := []struct {
+		e       *Evaluator
+		input   string
+		want    interface{}
+		wantErr string
+	}{
+		{a, "scoped()", "a", ""},
+		{a, "SCOPED()", "a", ""},
+		{b, "scoped()", "b", ""},
+		{b, "double(21)", 42.0, ""},
+		{b, "double()", nil, "error calling double: invalid number of arguments. expected 1, got 0"},
+		{a, "double(21)", nil, "undefined function double"},
+		{&Evaluator{}, "scoped()", nil, "undefined function scoped"},
+
+		// The default functions are available unless they are replaced
+		{a, "always()", true, ""},
+		{a, "startsWith('abc', 'a')", true, ""},
+		{b, "always()", false, ""},
+		{b, "contains('abc', 'b')", true, ""},
+		{&Evaluator{}, "always()", true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := tt.e.Evaluate(tt.input, nil)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("Evaluate() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+
+			if got.Value != tt.want {
+				t.Errorf("Evaluate() = %v, want %v", got.Value, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithFunctions_Merged(t *testing.T) {
+	e := NewEvaluator(
+		WithFunctions(map[string]FunctionDefinition{"one": {0, func(args ...*EvaluationResult) (*EvaluationResult, error) {
+			return NewNumber(1), nil
+		}}}),
+		WithFunctions(map[string]FunctionDefinition{"two": {0, func(args ...*EvaluationResult) (*EvaluationResult, error) {
+			return NewNumber(2), nil
+		}}}),
+	)
+	e.ExtendedFunctions = true
+
+	got, err := e.Evaluate("format('{0}{1}{2}', one(), two(), length('abc'))", nil)
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+
+	if got.Value != "123" {
+		t.Errorf("Evaluate() = %v, want %v", got.Value, "123")
+	}
+}
+
+func Test_fcall_CaseInsensitive(t *testing.T) {
+	calls := 0
+	t.Cleanup(func() {
+		delete(functions, "countcalls")
+	})
+
+	if err := RegisterFunction("countCalls", 0, func(args ...*EvaluationResult) (*EvaluationResult, error) {
+		calls++
+		return &EvaluationResult{float64(calls), &actionlint.NumberType{}}, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	inputs := []string{
+		"startsWith('test', 'te')",
+		"StartsWith('test', 'te')",
+		"startswith('test', 'te')",
+		"STARTSWITH('test', 'te')",
+		"fromJSON('true')",
+		"FROMJSON('true')",
+	}
+	for _, input := range inputs {
+		t.Run(input, func(t *testing.T) {
+			got, err := Evaluate(input, nil)
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+
+			if got.Value != true {
+				t.Errorf("Evaluate() = %v, want %v", got.Value, true)
+			}
+		})
+	}
+
+	for _, input := range []string{"countCalls()", "countcalls()", "COUNTCALLS()"} {
+		if _, err := Evaluate(input, nil); err != nil {
+			t.Fatalf("Evaluate() error = %v", err)
+		}
+	}
+
+	if calls != 3 {
+		t.Errorf("custom function called %d times, want 3", calls)
+	}
+}
+
+func Test_contains(t *testing.T) {
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{"contains('Hello world', 'llo')", true},
+		{"contains('Hello world', 'WORLD')", true},
+		{"contains('Hello world', 'xyz')", false},
+		{"contains(fromJSON('[\"A\"]'), 'a')", true},
+		{"contains(fromJSON('[\"a\", \"b\"]'), 'c')", false},
+		{"contains(fromJSON('[1,2]'), '1')", true},
+		{"contains(fromJSON('[1,2]'), 2)", true},
+		{"contains(fromJSON('[1,2]'), true)", true},
+		{"contains(fromJSON('[\"1\",\"2\"]'), 1)", true},
+		{"contains(fromJSON('[null]'), 0)", true},
+		{"contains(fromJSON('[]'), '')", false},
+		{"contains(fromJSON('[1,2]'), fromJSON('{}'))", false},
+		{"contains(fromJSON('[1,2]'), fromJSON('[1]'))", false},
+		{"contains(fromJSON('[{\"a\":1},{\"a\":2}]'), fromJSON('{\"a\":1}'))", false},
+		{"contains(fromJSON('[[1],[2]]'), fromJSON('[1]'))", false},
+		{"contains(fromJSON('[{\"a\":1}]'), 'Object')", false},
+		{"contains(fromJSON('[[1]]'), 'Array')", false},
+		{"contains(fromJSON('[{\"a\":1}, \"x\"]'), 'x')", true},
+		{"contains(fromJSON('{}'), 'x')", false},
+		{"contains(fromJSON('{\"x\": 1}'), 'x')", false},
+		{"contains(fromJSON('{}'), fromJSON('{}'))", false},
+
+		// Primitive searches are converted to strings
+		{"contains(123, '2')", true},
+		{"contains(123, 23)", true},
+		{"contains(123, 4)", false},
+		{"contains(123, true)", false},
+		{"contains(1.5, '.')", true},
+		{"contains(0xFF, '255')", true},
+		{"contains(1e21, 'e+')", true},
+		{"contains(true, 'RU')", true},
+		{"contains(false, 0)", false},
+		{"contains(null, '')", true},
+		{"contains('null', null)", true},
+
+		// Every string contains the empty string, array items are compared with it like with ==
+		{"contains('abc', '')", true},
+		{"contains('', '')", true},
+		{"contains('abc', null)", true},
+		{"contains(fromJSON('[1]'), '')", false},
+		{"contains(fromJSON('[\"a\"]'), '')", false},
+		{"contains(fromJSON('[\"\"]'), '')", true},
+		{"contains(fromJSON('[0]'), '')", true},
+		{"contains(fromJSON('[false]'), '')", true},
+		{"contains(fromJSON('[null]'), '')", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := Evaluate(tt.input, nil)
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+
+			if got.Value != tt.want {
+				t.Errorf("Evaluate() = %v, want %v", got.Value, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluate_StringFunctionsNull(t *testing.T) {
+	// null is a primitive and converted to an empty string, like on GitHub
+	tests := []struct {
+		input string
+		want  interface{}
+	}{
+		{"contains(null, 'x')", false},
+		{"contains(null, '')", true},
+		{"contains(null, null)", true},
+		{"contains('abc', null)", true},
+		{"contains(fromJSON('[null]'), null)", true},
+		{"contains(fromJSON('[\"\"]'), null)", true},
+		{"contains(fromJSON('[\"x\"]'), null)", false},
+		{"startsWith(null, 'x')", false},
+		{"startsWith(null, '')", true},
+		{"startsWith(null, null)", true},
+		{"startsWith('abc', null)", true},
+		{"endsWith(null, 'x')", false},
+		{"endsWith(null, '')", true},
+		{"endsWith(null, null)", true},
+		{"endsWith('abc', null)", true},
+		{"format(null)", ""},
+		{"format('{0}', null)", ""},
+		{"format('a{0}b', null)", "ab"},
+		{"join(null)", ""},
+		{"join(null, '-')", ""},
+		{"join(fromJSON('[null, null]'), null)", ""},
+		{"join(fromJSON('[1, 2]'), null)", "12"},
+		{"toJSON(null)", "null"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := Evaluate(tt.input, nil)
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+
+			if got.Value != tt.want {
+				t.Errorf("Evaluate() = %#v, want %#v", got.Value, tt.want)
+			}
+		})
+	}
+}
+
+func Test_contains_NonPrimitiveItem(t *testing.T) {
+	// Non-primitive items are never found, even if the array holds the same object. The elements are
+	// not compared at all.
+	obj := ContextData{"a": 1.0}
+	ctx := MapContext{"env": ContextData{"list": []interface{}{obj}, "item": obj}}
+
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{"env.list[0] == env.item", true},
+		{"contains(env.list, env.item)", false},
+		{"contains(env.list, env.list[0])", false},
+	}
+	for _, tt := range tests {
+		got, err := Evaluate(tt.input, ctx)
+		if err != nil {
+			t.Fatalf("Evaluate() error = %v", err)
+		}
+
+		if got.Value != tt.want {
+			t.Errorf("Evaluate(%q) = %v, want %v", tt.input, got.Value, tt.want)
+		}
+	}
+}
+
+func Test_startsWithEndsWith_Arrays(t *testing.T) {
+	ctx := MapContext{"values": []interface{}{"abc", "def"}}
+
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		// contains() searches the elements of arrays
+		{"contains(values, 'abc')", true},
+		{"contains(values, 'ab')", false},
+
+		// startsWith() and endsWith() convert arrays to strings
+		{"startsWith(values, 'abc')", false},
+		{"endsWith(values, 'def')", false},
+		{"startsWith(values, format('{0}', values))", true},
+		{"endsWith(values, format('{0}', values))", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := Evaluate(tt.input, ctx)
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+
+			if got.Value != tt.want {
+				t.Errorf("Evaluate() = %v, want %v", got.Value, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluate_UnicodeCase(t *testing.T) {
+	// Like GitHub, characters are converted to upper case one at a time
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{"contains('İstanbul', 'i')", false},
+		{"contains('İstanbul', 'İST')", true},
+		{"contains('istanbul', 'İ')", false},
+		{"contains('Iıİi', 'ii')", true},
+		{"contains('Straße', 'SS')", false},
+		{"contains('STRASSE', 'ß')", false},
+		{"contains('Ünïcödé', 'NÏCÖ')", true},
+		{"contains('👍🏽 ok', '🏽 OK')", true},
+		{"contains('日本語', '本')", true},
+		{"startsWith('Ωmega', 'ω')", true},
+		{"startsWith('ǆungla', 'Ǆ')", true},
+		{"startsWith('👍🏽', '👍')", true},
+		{"endsWith('ΣΟΦΟΣ', 'ς')", true},
+		{"endsWith('naïve', 'ÏVE')", true},
+		{"endsWith('café', 'E')", false},
+		{"'ΣΟΦΟΣ' == 'σοφος'", true},
+		{"'İ' == 'i'", false},
+		{"'Straße' == 'STRASSE'", false},
+		{"'\u212a' == 'k'", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := Evaluate(tt.input, nil)
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+
+			if got.Value != tt.want {
+				t.Errorf("Evaluate() = %v, want %v", got.Value, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluator_CaseSensitiveStrings(t *testing.T) {
+	tests := []struct {
+		input         string
+		want          bool
+		caseSensitive bool
+	}{
+		{"contains('ABC', 'abc')", true, false},
+		{"contains('ABC', 'b')", true, false},
+		{"contains(fromJSON('[\"ABC\"]'), 'abc')", true, false},
+		{"startsWith('ABC', 'ab')", true, false},
+		{"endsWith('ABC', 'bc')", true, false},
+		{"'ABC' == 'abc'", true, false},
+		{"'ABC' != 'abc'", false, true},
+		{"contains('ABC', 'B')", true, true},
+		{"contains(fromJSON('[\"ABC\"]'), 'ABC')", true, true},
+		{"'ABC' == 'ABC'", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := Evaluate(tt.input, nil)
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+
+			if got.Value != tt.want {
+				t.Errorf("Evaluate() = %v, want %v", got.Value, tt.want)
+			}
+
+			got, err = (&Evaluator{CaseSensitiveStrings: true}).Evaluate(tt.input, nil)
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+
+			if got.Value != tt.caseSensitive {
+				t.Errorf("Evaluate() with CaseSensitiveStrings = %v, want %v", got.Value, tt.caseSensitive)
+			}
+		})
+	}
+}
+
+func TestEvaluate_ToJSONKeyOrder(t *testing.T) {
+	ctx := MapContext{
+		"github": ContextData{
+			"sha":        "abc",
+			"ref":        "main",
+			"actor":      "octocat",
+			"event_name": "push",
+			"run_id":     float64(1),
+			"job":        "build",
+			"repository": "octo/repo",
+			"workflow":   "ci",
+			"event":      ContextData{"z": float64(1), "b": float64(2), "m": float64(3)},
+		},
+	}
+
+	want := `{
+  "actor": "octocat",
+  "event": {
+    "b": 2,
+    "m": 3,
+    "z": 1
+  },
+  "event_name": "push",
+  "job": "build",
+  "ref": "main",
+  "repository": "octo/repo",
+  "run_id": 1,
+  "sha": "abc",
+  "workflow": "ci"
+}`
+
+	for i := 0; i < 20; i++ {
+		got, err := Evaluate("toJSON(github)", ctx)
+		if err != nil {
+			t.Fatalf("Evaluate() error = %v", err)
+		}
+
+		if got.Value != want {
+			t.Fatalf("Evaluate() = %v, want %v", got.Value, want)
+		}
+	}
+
+	// Keys of decoded objects are sorted as well
+	got, err := Evaluate(`toJSON(fromJSON('{"c":1,"a":2,"b":3}'))`, nil)
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+
+	if want := "{\n  \"a\": 2,\n  \"b\": 3,\n  \"c\": 1\n}"; got.Value != want {
+		t.Errorf("Evaluate() = %v, want %v", got.Value, want)
+	}
+}
+
+func TestEvaluate_NumberRoundTrip(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		// Timestamps in milliseconds
+		{"toJSON(fromJSON('1609459200000'))", "1609459200000"},
+		{"format('{0}', fromJSON('1609459200000'))", "1609459200000"},
+		{"toJSON(fromJSON('{\"created\": 1609459200000}').created)", "1609459200000"},
+
+		// Largest integer that can be represented exactly
+		{"toJSON(fromJSON('9007199254740991'))", "9007199254740991"},
+		{"format('{0}', fromJSON('9007199254740991'))", "9.00719925474099E+15"},
+		{"format('{0}', fromJSON('999999999999999'))", "999999999999999"},
+		{"toJSON(fromJSON('-9007199254740991'))", "-9007199254740991"},
+
+		// Large counters
+		{"toJSON(fromJSON('[4294967296, 1000000000000000]'))", "[\n  4294967296,\n  1000000000000000\n]"},
+
+		// Beyond 2^53 numbers lose precision
+		{"toJSON(fromJSON('9007199254740993'))", "9007199254740992"},
+		{"toJSON(fromJSON('1e21'))", "1e+21"},
+		{"format('{0}', fromJSON('1e21'))", "1E+21"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := Evaluate(tt.input, nil)
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+
+			if got.Value != tt.want {
+				t.Errorf("Evaluate() = %v, want %v", got.Value, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluate_FromJSONNull(t *testing.T) {
+	for _, input := range []string{"fromJSON('null')", "fromJSON(' null ')", "fromJSON('[null]')[0]", "fromJSON('{\"a\": null}').a"} {
+		t.Run(input, func(t *testing.T) {
+			got, err := Evaluate(input, nil)
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+
+			if _, ok := got.Type.(*actionlint.NullType); !ok || got.Value != nil {
+				t.Errorf("Evaluate() = %#v (%v), want null", got.Value, got.Type)
+			}
+
+			if s := got.CoerceString(); s != "" {
+				t.Errorf("CoerceString() = %q, want empty string", s)
+			}
+
+			if n := got.CoerceNumber(); n != 0 {
+				t.Errorf("CoerceNumber() = %v, want 0", n)
+			}
+		})
+	}
+
+	got, err := Evaluate("fromJSON('[null, 1]')", nil)
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+
+	if want := "array<any>"; got.Type.String() != want {
+		t.Errorf("Evaluate() type = %v, want %v", got.Type, want)
+	}
+}
+
+func TestEvaluator_PreserveNumberPrecision(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    interface{}
+		wantStd interface{}
+	}{
+		{"toJSON(fromJSON('9007199254740993'))", "9007199254740993", "9007199254740992"},
+		{"format('{0}', fromJSON('9007199254740993'))", "9007199254740993", "9.00719925474099E+15"},
+		{"toJSON(fromJSON('{\"id\": 12345678901234567890}'))", "{\n  \"id\": 12345678901234567890\n}", "{\n  \"id\": 12345678901234567000\n}"},
+		{"toJSON(fromJSON('[0.10, 1e2]'))", "[\n  0.10,\n  1e2\n]", "[\n  0.1,\n  100\n]"},
+		{"join(fromJSON('[1.50, 2]'), '-')", "1.50-2", "1.5-2"},
+
+		// Comparisons convert to float64
+		{"fromJSON('9007199254740993') == fromJSON('9007199254740992')", true, true},
+		{"fromJSON('1.50') == 1.5", true, true},
+		{"fromJSON('1.50') == '1.5'", true, true},
+		{"fromJSON('2') > fromJSON('10')", false, false},
+		{"contains(fromJSON('[1.0, 2]'), 1)", true, true},
+		{"fromJSON('[\"a\", \"b\"]')[fromJSON('1.0')]", "b", "b"},
+		{"fromJSON('0') || 'default'", "default", "default"},
+		{"!fromJSON('0.0')", true, true},
+		{"fromJSON('1e400') > 1", true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := (&Evaluator{PreserveNumberPrecision: true}).Evaluate(tt.input, nil)
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+
+			if got.Value != tt.want {
+				t.Errorf("Evaluate() = %v, want %v", got.Value, tt.want)
+			}
+
+			// Without the option, numbers are rounded to float64
+			got, err = Evaluate(tt.input, nil)
+			if tt.wantStd == nil {
+				if err == nil {
+					t.Errorf("Evaluate() without option did not fail")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Evaluate() without option error = %v", err)
+			}
+
+			if got.Value != tt.wantStd {
+				t.Errorf("Evaluate() without option = %v, want %v", got.Value, tt.wantStd)
+			}
+		})
+	}
+}
+
+func TestEvaluator_PreserveNumberPrecision_Invalid(t *testing.T) {
+	for _, input := range []string{"{", "1 2", "[1] x", "01", "1.", "-"} {
+		_, err := (&Evaluator{PreserveNumberPrecision: true}).Evaluate(fmt.Sprintf("fromJSON('%s')", input), nil)
+		if err == nil {
+			t.Errorf("Evaluate() of %q did not fail", input)
+		}
+	}
+
+	got, err := (&Evaluator{PreserveNumberPrecision: true}).Evaluate("fromJSON(' 1.0 ')", nil)
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+
+	if got.Type.String() != "number" || got.CoerceString() != "1.0" {
+		t.Errorf("Evaluate() = %v (%v), want number 1.0", got.Value, got.Type)
+	}
+}
+
+func TestEvaluator_RejectDuplicateJSONKeys(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    interface{}
+		wantErr bool
+	}{
+		{`fromJSON('{"a":1,"a":2}').a`, float64(2), true},
+		{`fromJSON('{"a":{"b":1,"b":2}}').a.b`, float64(2), true},
+		{`fromJSON('[{"a":1},{"a":2,"c":[{"x":1,"x":1}]}]')[1].a`, float64(2), true},
+		{`fromJSON('{"a":1,"b":{"a":2}}').b.a`, float64(2), false},
+		{`fromJSON('[{"a":1},{"a":2}]')[1].a`, float64(2), false},
+		{`fromJSON('{"a":[1,{"b":[]}],"b":{"a":[]}}').a[0]`, float64(1), false},
+		{`fromJSON('{"a":1,"A":2}').a`, float64(1), true},
+		{`fromJSON('{"Key":1,"KEY":2}').key`, float64(2), true},
+		{`fromJSON('{"a":1,"b":{"A":2}}').b.a`, float64(2), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			// Last value wins by default
+			got, err := Evaluate(tt.input, nil)
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+
+			if got.Value != tt.want {
+				t.Errorf("Evaluate() = %v, want %v", got.Value, tt.want)
+			}
+
+			got, err = (&Evaluator{RejectDuplicateJSONKeys: true}).Evaluate(tt.input, nil)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Evaluate() = %v, want error", got.Value)
+				}
+
+				if !strings.Contains(err.Error(), "could not parse JSON: duplicate key") {
+					t.Errorf("Evaluate() error = %v, want duplicate key error", err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+
+			if got.Value != tt.want {
+				t.Errorf("Evaluate() = %v, want %v", got.Value, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluator_MaxJSONSize(t *testing.T) {
+	ctx := MapContext{"env": ContextData{"PAYLOAD": `{"items": [{"id": 1}, {"id": 2}]}`}}
+
+	tests := []struct {
+		maxSize int
+		wantErr bool
+	}{
+		{0, false},
+		{100, false},
+		{33, false},
+		{32, true},
+		{1, true},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.maxSize), func(t *testing.T) {
+			ev := &Evaluator{MaxJSONSize: tt.maxSize}
+			got, err := ev.Evaluate("fromJSON(env.PAYLOAD).items[1].id", ctx)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Evaluate() = %v, want error", got)
+				}
+
+				if want := "error calling fromJSON: JSON input of 33 bytes exceeds the maximum size of " + fmt.Sprint(tt.maxSize) + " bytes"; !strings.HasSuffix(err.Error(), want) {
+					t.Errorf("Evaluate() error = %v, want suffix %v", err, want)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+
+			if got.Value != float64(2) {
+				t.Errorf("Evaluate() = %v, want %v", got.Value, 2)
+			}
+		})
+	}
+}
+
+func TestEvaluator_MaxJSONSize_Empty(t *testing.T) {
+	// Empty input is invalid JSON and not an empty object
+	ev := &Evaluator{MaxJSONSize: 1}
+	if _, err := ev.Evaluate("fromJSON('')", nil); err == nil || err.Error() != "error calling fromJSON: could not parse JSON: unexpected end of JSON input" {
+		t.Errorf("Evaluate() error = %v, want parse error", err)
+	}
+}
+
+func TestEvaluator_MaxStringLength(t *testing.T) {
+	ctx := MapContext{"env": ContextData{"ITEMS": []interface{}{"abc", "def"}}}
+
+	tests := []struct {
+		input   string
+		want    string
+		wantErr string
+	}{
+		{"format('{0}-{0}', 'abc')", "abc-abc", ""},
+		{"format('{0}-{0}-{0}', 'abc')", "", "error calling format: string of 11 bytes exceeds the maximum length of 8 bytes"},
+		{"join(env.ITEMS, ', ')", "abc, def", ""},
+		{"join(env.ITEMS, ' - ')", "", "error calling join: string of 9 bytes exceeds the maximum length of 8 bytes"},
+		{"join('abcdefghij')", "abcdefghij", ""},
+		{"toJSON('abcdef')", `"abcdef"`, ""},
+		{"toJSON(env.ITEMS)", "", "error calling toJSON: string of 20 bytes exceeds the maximum length of 8 bytes"},
+		{"'abcdefghij'", "abcdefghij", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			ev := &Evaluator{MaxStringLength: 8}
+			got, err := ev.Evaluate(tt.input, ctx)
+			if tt.wantErr != "" {
+				if err == nil || !strings.HasSuffix(err.Error(), tt.wantErr) {
+					t.Errorf("Evaluate() error = %v, want suffix %v", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+
+			if got.Value != tt.want {
+				t.Errorf("Evaluate() = %v, want %v", got.Value, tt.want)
+			}
+		})
+	}
+
+	// format() stops at the first placeholder exceeding the limit instead of building the whole string
+	big := NewString(strings.Repeat("x", 1<<20))
+	_, err := formatString(strings.Repeat("{0}", 1000), []*EvaluationResult{big}, 3<<20)
+	if want := "string of 4194304 bytes exceeds the maximum length of 3145728 bytes"; err == nil || err.Error() != want {
+		t.Errorf("formatString() error = %v, want %v", err, want)
+	}
+
+	if _, err := formatString("ab{{}}", nil, 3); err == nil || err.Error() != "string of 4 bytes exceeds the maximum length of 3 bytes" {
+		t.Errorf("formatString() error = %v", err)
+	}
+}
+
+func TestEvaluate_UndefinedFunction(t *testing.T) {
+	tests := []struct {
+		input          string
+		wantSuggestion string
+		wantMsg        string
+	}{
+		{"foobar(1)", "", "undefined function foobar"},
+		{"fromJSN('{}')", "fromJSON", "undefined function fromJSN, did you mean fromJSON?"},
+		{"startWith('a', 'b')", "startsWith", "undefined function startWith, did you mean startsWith?"},
+		{"tojsn(1)", "toJSON", "undefined function tojsn, did you mean toJSON?"},
+		{"sucess()", "success", "undefined function sucess, did you mean success?"},
+		{"x()", "", "undefined function x"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := Evaluate(tt.input, nil)
+
+			var ferr *UndefinedFunctionError
+			if !errors.As(err, &ferr) {
+				t.Fatalf("Evaluate() error = %v, want UndefinedFunctionError", err)
+			}
+
+			if ferr.Suggestion != tt.wantSuggestion {
+				t.Errorf("Suggestion = %q, want %q", ferr.Suggestion, tt.wantSuggestion)
+			}
+
+			if ferr.Error() != tt.wantMsg {
+				t.Errorf("Error() = %q, want %q", ferr.Error(), tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestEvaluator_UndefinedFunction_Suggestion(t *testing.T) {
+	ev := NewEvaluator(WithFunctions(map[string]FunctionDefinition{
+		"deployTarget": {0, func(args ...*EvaluationResult) (*EvaluationResult, error) { return NewString("prod"), nil }},
+	}))
+	ev.ExtendedFunctions = true
+	ev.DisabledFunctions = []string{"fromJSON"}
+
+	tests := []struct {
+		input          string
+		wantSuggestion string
+	}{
+		{"deployTarge()", "deployTarget"},
+		{"lenght('a')", "length"},
+		{"trimm('a')", "trim"},
+		{"fromJSN('{}')", ""},
+		{"tojsn(1)", "toJSON"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := ev.Evaluate(tt.input, nil)
+
+			var ferr *UndefinedFunctionError
+			if !errors.As(err, &ferr) {
+				t.Fatalf("Evaluate() error = %v, want UndefinedFunctionError", err)
+			}
+
+			if ferr.Suggestion != tt.wantSuggestion {
+				t.Errorf("Suggestion = %q, want %q", ferr.Suggestion, tt.wantSuggestion)
+			}
+		})
+	}
+
+	// Extended functions are only suggested if they are enabled
+	_, err := Evaluate("lenght('a')", nil)
+
+	var ferr *UndefinedFunctionError
+	if !errors.As(err, &ferr) || ferr.Suggestion != "" {
+		t.Errorf("Evaluate() error = %v, want UndefinedFunctionError without suggestion", err)
+	}
+}
+
+func TestEvaluator_DisabledFunctions(t *testing.T) {
+	ev := &Evaluator{Workspace: t.TempDir(), DisabledFunctions: []string{"hashFiles", "FROMJSON", "notAFunction"}}
+
+	tests := []struct {
+		input   string
+		wantErr string
+		wantCol int
+	}{
+		{"hashFiles('**/go.sum')", "function hashFiles is disabled", 1},
+		{"HASHFILES('**/go.sum')", "function HASHFILES is disabled", 1},
+		{"format('{0}', fromJson('1'))", "function fromJson is disabled", 15},
+		{"startsWith('abc', 'a') && hashFiles()", "function hashFiles is disabled", 27},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := ev.Evaluate(tt.input, nil)
+
+			var ferr *DisabledFunctionError
+			if !errors.As(err, &ferr) {
+				t.Fatalf("Evaluate() error = %v, want DisabledFunctionError", err)
+			}
+
+			if ferr.Error() != tt.wantErr {
+				t.Errorf("Error() = %q, want %q", ferr.Error(), tt.wantErr)
+			}
+
+			if pos := ferr.Pos(); pos == nil || pos.Col != tt.wantCol {
+				t.Errorf("Pos() = %v, want column %d", pos, tt.wantCol)
+			}
+		})
+	}
+
+	// Other functions are still available, and unknown ones are still undefined
+	got, err := ev.Evaluate("format('{0}', toJSON(contains('abc', 'b')))", nil)
+	if err != nil || got.Value != "true" {
+		t.Errorf("Evaluate() = %v, %v, want %v", got, err, "true")
+	}
+
+	var uerr *UndefinedFunctionError
+	if _, err := ev.Evaluate("notAFunction()", nil); !errors.As(err, &uerr) {
+		t.Errorf("Evaluate() error = %v, want UndefinedFunctionError", err)
+	}
+
+	var ferr *DisabledFunctionError
+	if _, err := ev.InferType("hashFiles('a')", nil); !errors.As(err, &ferr) {
+		t.Errorf("InferType() error = %v, want DisabledFunctionError", err)
+	}
+}
+
+func Test_editDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"", "abc", 3},
+		{"fromjson", "fromjson", 0},
+		{"fromjsn", "fromjson", 1},
+		{"kitten", "sitting", 3},
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestEvaluate_JoinSeparator(t *testing.T) {
+	// Separators are converted to strings like any other value
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"join(fromJSON('[1,2]'), 0)", "102"},
+		{"join(fromJSON('[1,2]'), 1.5)", "11.52"},
+		{"join(fromJSON('[1,2]'), fromJSON('-0'))", "102"},
+		{"join(fromJSON('[1,2]'), true)", "1true2"},
+		{"join(fromJSON('[1,2]'), false)", "1false2"},
+		{"join(fromJSON('[1,2]'), null)", "12"},
+		{"join(fromJSON('[1,2]'), fromJSON('[\"-\"]'))", "1Array2"},
+		{"join(fromJSON('[1,2]'), fromJSON('{\"a\": \"-\"}'))", "1Object2"},
+		{"join(fromJSON('[1]'), fromJSON('[]'))", "1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := Evaluate(tt.input, nil)
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+
+			if got.Value != tt.want {
+				t.Errorf("Evaluate() = %v, want %v", got.Value, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluate_BooleanStrings(t *testing.T) {
+	// Booleans are always converted to lower case strings
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"format('{0}', true)", "true"},
+		{"format('{0}', false)", "false"},
+		{"format('{0}{1}', fromJSON('true'), github.flag)", "truefalse"},
+		{"format('{0}', 1 == 1)", "true"},
+		{"join(fromJSON('[true,false]'))", "true,false"},
+		{"join(fromJSON('[true,false]'), true)", "truetruefalse"},
+		{"join(true)", "true"},
+		{"join(github.flags, ' ')", "false true"},
+		{"toJSON(true)", "true"},
+		{"toJSON(fromJSON('[false]'))", "[\n  false\n]"},
+	}
+	ctx := MapContext{"github": ContextData{"flag": false, "flags": []interface{}{false, true}}}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := Evaluate(tt.input, ctx)
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+
+			if got.Value != tt.want {
+				t.Errorf("Evaluate() = %q, want %q", got.Value, tt.want)
+			}
+		})
+	}
+}
+
+// BenchmarkJoin joins an array of mixed primitives. Converting the elements without determining their
+// types and printing integers with strconv.FormatInt reduced the time from ~46µs to ~36µs per op.
+func BenchmarkJoin(b *testing.B) {
+	values := make([]interface{}, 1000)
+	for i := range values {
+		switch i % 3 {
+		case 0:
+			values[i] = fmt.Sprintf("item-%d", i)
+		case 1:
+			values[i] = float64(i)
+		case 2:
+			values[i] = i%2 == 0
+		}
+	}
+
+	ctx := MapContext{"matrix": ContextData{"items": values}}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := Evaluate("join(matrix.items, ', ')", ctx); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
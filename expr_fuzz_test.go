@@ -0,0 +1,73 @@
+package expr
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Panics during Evaluate and EvaluateNode are recovered and returned as errors with this prefix
+const panicErrPrefix = "could not evaluate expression"
+
+// fuzzEvaluator returns an evaluator with a workspace of its own, hashFiles() cannot read files outside
+// of it, so the fuzzer can call it safely.
+func fuzzEvaluator(f *testing.F) *Evaluator {
+	dir := f.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "x"), []byte("x"), 0o644); err != nil {
+		f.Fatal(err)
+	}
+
+	return &Evaluator{Workspace: dir}
+}
+
+func FuzzFromJSON(f *testing.F) {
+	for _, seed := range []string{"", " ", "{}", "[]", "null", "1", "1e400", `"a"`, `{"a":[1,{"b":null}]}`, `{"a":1,"a":2}`, "{", `[1,`, "\x00", `{"\u0000":"\ud800"}`} {
+		f.Add(seed)
+	}
+
+	n, err := parse("toJSON(fromJSON(env.INPUT).a) || join(fromJSON(env.INPUT).*, ',') || fromJSON(env.INPUT)[0]", 0)
+	if err != nil {
+		f.Fatal(err)
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		ctx := MapContext{"env": ContextData{"INPUT": input}}
+
+		for _, ev := range []*Evaluator{{}, {RejectDuplicateJSONKeys: true, MaxJSONSize: 64}} {
+			if _, err := ev.EvaluateNode(n, ctx); err != nil && strings.HasPrefix(err.Error(), panicErrPrefix) {
+				t.Errorf("EvaluateNode() with %q panicked: %v", input, err)
+			}
+		}
+	})
+}
+
+func FuzzEvaluate(f *testing.F) {
+	for _, seed := range []string{"1", "github.event.issue.title", "fromJSON('{}').a[0]", "format('{0}{{', 1)", "contains(fromJSON('[1]'), 1) && !success()", "a.*.b['c']", "hashFiles('x')"} {
+		f.Add(seed)
+	}
+
+	ev := fuzzEvaluator(f)
+	ctx := MapContext{"github": ContextData{"event": ContextData{"issue": ContextData{"title": "x"}}}}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		if _, err := ev.Evaluate(input, ctx); err != nil && strings.HasPrefix(err.Error(), panicErrPrefix) {
+			t.Errorf("Evaluate(%q) panicked: %v", input, err)
+		}
+	})
+}
+
+func FuzzEvaluateString(f *testing.F) {
+	for _, seed := range []string{"", "plain", "${{ 1 }}", "a-${{ github.sha }}-b", "${{ '}}' }}", "${{", "}} ${{ }}", "${{ '${{' }}", "${{ hashFiles('*') }}"} {
+		f.Add(seed)
+	}
+
+	ev := fuzzEvaluator(f)
+	ctx := MapContext{"github": ContextData{"sha": "abc"}}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		if _, err := ev.EvaluateString(input, ctx); err != nil && strings.HasPrefix(err.Error(), panicErrPrefix) {
+			t.Errorf("EvaluateString(%q) panicked: %v", input, err)
+		}
+	})
+}
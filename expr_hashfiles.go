@@ -0,0 +1,154 @@
+package expr
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// hashFiles computes the hash GitHub's hashFiles() function returns for the files in baseDir
+// matching the given patterns: the SHA-256 of the concatenated SHA-256 hashes of each file. Patterns
+// starting with ! exclude files matched by earlier patterns. If no file matches, an empty string is
+// returned.
+func hashFiles(baseDir string, patterns []string) (string, error) {
+	matchers := make([]globMatcher, len(patterns))
+	for i, p := range patterns {
+		m, err := newGlobMatcher(p)
+		if err != nil {
+			return "", err
+		}
+
+		matchers[i] = m
+	}
+
+	result := sha256.New()
+	matched := false
+
+	err := filepath.WalkDir(baseDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(baseDir, path)
+		if err != nil {
+			return err
+		}
+
+		if !matchGlobs(matchers, filepath.ToSlash(rel)) {
+			return nil
+		}
+
+		hash, err := hashFile(path)
+		if err != nil {
+			return err
+		}
+
+		result.Write(hash)
+		matched = true
+
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if !matched {
+		return "", nil
+	}
+
+	return hex.EncodeToString(result.Sum(nil)), nil
+}
+
+func hashFile(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+
+	return h.Sum(nil), nil
+}
+
+type globMatcher struct {
+	negate bool
+	re     *regexp.Regexp
+}
+
+// newGlobMatcher compiles a glob pattern. `**` matches any number of directories, `*` any sequence of
+// characters except `/`, and `?` a single character except `/`.
+func newGlobMatcher(pattern string) (globMatcher, error) {
+	negate := false
+	if strings.HasPrefix(pattern, "!") {
+		negate = true
+		pattern = pattern[1:]
+	}
+
+	pattern = strings.TrimPrefix(filepath.ToSlash(pattern), "./")
+
+	var sb strings.Builder
+	sb.WriteString("^")
+
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+
+		switch c {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				i++
+
+				if i+1 < len(pattern) && pattern[i+1] == '/' {
+					// `**/` also matches no directory at all
+					i++
+					sb.WriteString("(?:.*/)?")
+				} else {
+					sb.WriteString(".*")
+				}
+			} else {
+				sb.WriteString("[^/]*")
+			}
+
+		case '?':
+			sb.WriteString("[^/]")
+
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	sb.WriteString("$")
+
+	re, err := regexp.Compile(sb.String())
+	if err != nil {
+		return globMatcher{}, err
+	}
+
+	return globMatcher{negate, re}, nil
+}
+
+// matchGlobs reports whether path is matched by the given patterns. Later patterns take precedence
+// over earlier ones.
+func matchGlobs(matchers []globMatcher, path string) bool {
+	matched := false
+
+	for _, m := range matchers {
+		if m.re.MatchString(path) {
+			matched = !m.negate
+		}
+	}
+
+	return matched
+}
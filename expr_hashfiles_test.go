@@ -0,0 +1,125 @@
+package expr
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rhysd/actionlint"
+)
+
+func writeTestFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+func Test_hashFiles(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"a.txt":     "hello\n",
+		"sub/b.txt": "world\n",
+		"sub/c.md":  "x",
+	})
+
+	tests := []struct {
+		name     string
+		patterns []string
+		want     string
+	}{
+		{"all txt files", []string{"**/*.txt"}, "96cb8058ed58b58f8fc0ad459bacf81108599b403e91674460ccb089f8ebb9db"},
+		{"multiple patterns", []string{"a.txt", "sub/b.txt"}, "96cb8058ed58b58f8fc0ad459bacf81108599b403e91674460ccb089f8ebb9db"},
+		{"single file", []string{"sub/*.txt"}, "dc0a7d4047ef3bfc9f2a86dc270e6e67cb7a3a7c071acedf5899382f6040d106"},
+		{"negated pattern", []string{"**/*.txt", "!a.txt"}, "dc0a7d4047ef3bfc9f2a86dc270e6e67cb7a3a7c071acedf5899382f6040d106"},
+		{"no match", []string{"**/*.go"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := hashFiles(dir, tt.patterns)
+			if err != nil {
+				t.Fatalf("hashFiles() error = %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("hashFiles() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluator_HashFiles(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"a.txt":     "hello\n",
+		"sub/b.txt": "world\n",
+	})
+
+	ev := &Evaluator{Workspace: dir}
+	got, err := ev.Evaluate("hashFiles('**/*.txt')", nil)
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+
+	want := &EvaluationResult{"96cb8058ed58b58f8fc0ad459bacf81108599b403e91674460ccb089f8ebb9db", &actionlint.StringType{}}
+	if got.Value != want.Value {
+		t.Errorf("Evaluate() = %v, want %v", got, want)
+	}
+}
+
+func TestEvaluator_HashFiles_Secrets(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{"a.txt": "hello\n"})
+
+	// Patterns are matched with the actual value of secrets
+	ev := &Evaluator{Workspace: dir, MaskSecrets: true}
+	got, err := ev.Evaluate("hashFiles(secrets.PATTERN) == hashFiles('*.txt') && hashFiles('*.txt') != ''", MapContext{"secrets": ContextData{"PATTERN": "*.txt"}})
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+
+	if got.Value != true {
+		t.Errorf("Evaluate() = %v, want true", got.Value)
+	}
+}
+
+func TestEvaluator_HashFiles_Workspace(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{"go.sum": "hello\n"})
+
+	// Patterns are resolved against the workspace, not the working directory
+	for _, workspace := range []string{dir, dir + string(filepath.Separator)} {
+		got, err := (&Evaluator{Workspace: workspace}).Evaluate("hashFiles('go.sum')", nil)
+		if err != nil {
+			t.Fatalf("Evaluate() error = %v", err)
+		}
+
+		if want := "ecb65bb98f9d905b70458986c39fcbad7715e5f2fcc3b1f07767d7c83e2438cc"; got.Value != want {
+			t.Errorf("Evaluate() = %v, want %v", got.Value, want)
+		}
+	}
+
+	got, err := (&Evaluator{Workspace: t.TempDir()}).Evaluate("hashFiles('go.sum')", nil)
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+
+	if got.Value != "" {
+		t.Errorf("Evaluate() in empty workspace = %v, want empty string", got.Value)
+	}
+}
+
+func TestEvaluator_HashFiles_NoWorkspace(t *testing.T) {
+	_, err := (&Evaluator{}).Evaluate("hashFiles('**/*.txt')", nil)
+
+	want := "error calling hashFiles: no workspace to resolve files in, Evaluator.Workspace is not set"
+	if err == nil || err.Error() != want {
+		t.Errorf("Evaluate() error = %v, want %v", err, want)
+	}
+}
@@ -0,0 +1,275 @@
+package expr
+
+import (
+	"strings"
+
+	errs "github.com/pkg/errors"
+
+	"github.com/rhysd/actionlint"
+)
+
+// TypeContext provides the types of the top-level contexts an expression can reference, for
+// inferring the type of an expression without evaluating it.
+type TypeContext interface {
+	// LookupType returns the type of the context with the given name. Names are always lower case.
+	LookupType(name string) (actionlint.ExprType, bool)
+}
+
+// MapTypeContext is a TypeContext backed by a map from context names to their types.
+type MapTypeContext map[string]actionlint.ExprType
+
+func (c MapTypeContext) LookupType(name string) (actionlint.ExprType, bool) {
+	if t, ok := c[name]; ok {
+		return t, true
+	}
+
+	for k, t := range c {
+		if strings.EqualFold(k, name) {
+			return t, true
+		}
+	}
+
+	return nil, false
+}
+
+// InferType parses the given expression, without the surrounding `${{ }}`, and returns the type of
+// its result using the default evaluator. See Evaluator.InferType.
+func InferType(expr string, ctx TypeContext) (actionlint.ExprType, error) {
+	return (&Evaluator{}).InferType(expr, ctx)
+}
+
+// InferType parses the given expression, without the surrounding `${{ }}`, and returns the type of
+// its result without evaluating it. Contexts missing from ctx, and the results of fromJSON() with
+// an argument that is not a string literal, have the type any. `&&` and `||` have the merged type of
+// both operands. Calls to undefined functions, or with the wrong number of arguments, fail like
+// during evaluation.
+func (e *Evaluator) InferType(expr string, ctx TypeContext) (actionlint.ExprType, error) {
+	n, err := parse(expr, e.MaxDepth)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.InferNodeType(n, ctx)
+}
+
+// InferNodeType returns the type of the result of an already parsed expression without evaluating
+// it.
+func (e *Evaluator) InferNodeType(n actionlint.ExprNode, ctx TypeContext) (actionlint.ExprType, error) {
+	switch tn := n.(type) {
+
+	//
+	// Literals
+	//
+	case *actionlint.IntNode, *actionlint.FloatNode:
+		return &actionlint.NumberType{}, nil
+
+	case *actionlint.StringNode:
+		return &actionlint.StringType{}, nil
+
+	case *actionlint.BoolNode:
+		return &actionlint.BoolType{}, nil
+
+	case *actionlint.NullNode:
+		return &actionlint.NullType{}, nil
+
+	//
+	// Context access
+	//
+	case *actionlint.VariableNode:
+		if e.UnknownContextIsError && !isKnownContext(tn.Name) {
+			return nil, &UnknownContextError{Name: tn.Name, pos: tokenPos(tn.Token())}
+		}
+
+		if ctx != nil {
+			if t, ok := ctx.LookupType(tn.Name); ok {
+				return t, nil
+			}
+		}
+
+		return &actionlint.AnyType{}, nil
+
+	case *actionlint.ObjectDerefNode:
+		t, err := e.InferNodeType(tn.Receiver, ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		// Property access on the result of an object filter is applied to every element
+		if at, ok := t.(*actionlint.ArrayType); ok && at.Deref {
+			return &actionlint.ArrayType{Elem: propertyType(at.Elem, tn.Property), Deref: true}, nil
+		}
+
+		return propertyType(t, tn.Property), nil
+
+	case *actionlint.IndexAccessNode:
+		idx, err := e.InferNodeType(tn.Index, ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		t, err := e.InferNodeType(tn.Operand, ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		switch tt := t.(type) {
+		case *actionlint.ArrayType:
+			return tt.Elem, nil
+
+		case *actionlint.ObjectType:
+			if s, ok := tn.Index.(*actionlint.StringNode); ok {
+				return propertyType(tt, s.Value), nil
+			}
+
+			switch idx.(type) {
+			case *actionlint.StringType, actionlint.StringType, *actionlint.AnyType, actionlint.AnyType:
+				if tt.Mapped == nil {
+					// Any of the properties of a strict object may be accessed
+					return &actionlint.AnyType{}, nil
+				}
+
+				return tt.Mapped, nil
+			}
+
+			// Objects have no properties for other indexes
+			return &actionlint.NullType{}, nil
+
+		case *actionlint.AnyType, actionlint.AnyType:
+			return &actionlint.AnyType{}, nil
+		}
+
+		// Indexes of primitives are null
+		return &actionlint.NullType{}, nil
+
+	case *actionlint.ArrayDerefNode:
+		t, err := e.InferNodeType(tn.Receiver, ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		elem := actionlint.ExprType(&actionlint.AnyType{})
+		if at, ok := t.(*actionlint.ArrayType); ok && !at.Deref {
+			elem = at.Elem
+		} else if ot, ok := t.(*actionlint.ObjectType); ok && ot.Mapped != nil && len(ot.Props) == 0 {
+			elem = ot.Mapped
+		}
+
+		return &actionlint.ArrayType{Elem: elem, Deref: true}, nil
+
+	//
+	// Function call
+	//
+	case *actionlint.FuncCallNode:
+		for _, arg := range tn.Args {
+			if _, err := e.InferNodeType(arg, ctx); err != nil {
+				return nil, err
+			}
+		}
+
+		pos := tokenPos(tn.Token())
+		key := strings.ToLower(tn.Callee)
+		funcDef, ok := e.lookupFunction(key)
+		if !ok {
+			return nil, &UndefinedFunctionError{Name: tn.Callee, Suggestion: e.suggestFunction(key), pos: pos}
+		}
+
+		if e.disabledFunction(key) {
+			return nil, &DisabledFunctionError{Name: tn.Callee, pos: pos}
+		}
+
+		if err := funcDef.checkArgs(len(tn.Args)); err != nil {
+			return nil, &EvaluationError{Func: tn.Callee, Err: err, pos: pos}
+		}
+
+		// The type of parsed JSON is only known for literals
+		if key == "fromjson" && !funcDef.volatile {
+			if s, ok := tn.Args[0].(*actionlint.StringNode); ok {
+				result, err := funcDef.call(e, NewString(s.Value))
+				if err != nil {
+					return nil, &EvaluationError{Func: tn.Callee, Err: err, pos: pos}
+				}
+
+				return result.Type, nil
+			}
+		}
+
+		if funcDef.returns == nil {
+			return &actionlint.AnyType{}, nil
+		}
+
+		return funcDef.returns, nil
+
+	//
+	// Operators
+	//
+	case *actionlint.NotOpNode:
+		if _, err := e.InferNodeType(tn.Operand, ctx); err != nil {
+			return nil, err
+		}
+
+		return &actionlint.BoolType{}, nil
+
+	case *actionlint.CompareOpNode:
+		if _, err := e.InferNodeType(tn.Left, ctx); err != nil {
+			return nil, err
+		}
+
+		if _, err := e.InferNodeType(tn.Right, ctx); err != nil {
+			return nil, err
+		}
+
+		return &actionlint.BoolType{}, nil
+
+	case *actionlint.LogicalOpNode:
+		// Either operand is returned
+		left, err := e.InferNodeType(tn.Left, ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		right, err := e.InferNodeType(tn.Right, ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		return mergeTypes(left, right), nil
+	}
+
+	return nil, errs.Errorf("unknown node type: %T", n)
+}
+
+// propertyType returns the type of the given property of a value of type t. Properties of anything
+// but objects are null.
+func propertyType(t actionlint.ExprType, property string) actionlint.ExprType {
+	switch tt := t.(type) {
+	case *actionlint.ObjectType:
+		for k, pt := range tt.Props {
+			if strings.EqualFold(k, property) {
+				return pt
+			}
+		}
+
+		// Unknown properties of strict objects evaluate to null
+		if tt.Mapped == nil {
+			return &actionlint.NullType{}
+		}
+
+		return tt.Mapped
+
+	case *actionlint.AnyType, actionlint.AnyType:
+		return &actionlint.AnyType{}
+	}
+
+	return &actionlint.NullType{}
+}
+
+// mergeTypes returns the type of values that are either of type l or r. The Merge methods of
+// actionlint's primitive types do not recognize pointers to types, like the ones used in this package,
+// so equal types are handled here.
+func mergeTypes(l, r actionlint.ExprType) actionlint.ExprType {
+	if l.String() == r.String() {
+		return l
+	}
+
+	return l.Merge(r)
+}
@@ -0,0 +1,136 @@
+package expr
+
+import (
+	"testing"
+
+	"github.com/rhysd/actionlint"
+)
+
+func TestInferType(t *testing.T) {
+	ctx := MapTypeContext{
+		"github": actionlint.NewObjectType(map[string]actionlint.ExprType{
+			"event_name": &actionlint.StringType{},
+			"run_number": &actionlint.NumberType{},
+		}),
+		"env":    actionlint.NewMapObjectType(&actionlint.StringType{}),
+		"inputs": actionlint.NewStrictObjectType(map[string]actionlint.ExprType{"debug": &actionlint.BoolType{}}),
+		"matrix": &actionlint.ArrayType{Elem: actionlint.NewStrictObjectType(map[string]actionlint.ExprType{"os": &actionlint.StringType{}})},
+	}
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"1", "number"},
+		{"1.5", "number"},
+		{"'a'", "string"},
+		{"true", "bool"},
+		{"null", "null"},
+
+		{"contains(x, y)", "bool"},
+		{"startsWith(github.event_name, 'pull')", "bool"},
+		{"endsWith('a', 'b')", "bool"},
+		{"format('{0}', 1)", "string"},
+		{"join(matrix.*.os)", "string"},
+		{"toJSON(github)", "string"},
+		{"hashFiles('**/go.sum')", "string"},
+		{"success() && !cancelled()", "bool"},
+
+		// The type of parsed JSON is only known for literals
+		{"fromJSON(x)", "any"},
+		{"fromJSON(env.CONFIG)", "any"},
+		{"fromJSON('1')", "number"},
+		{"fromJSON('[1, 2]')", "array<number>"},
+		{"fromJSON('{\"a\": true}')", "{string => any}"},
+		{"fromJSON('{\"a\": true}').a", "bool"},
+
+		{"github.event_name", "string"},
+		{"github.EVENT_NAME", "string"},
+		{"github.run_number", "number"},
+		{"github.unknown", "any"},
+		{"github['event_name']", "string"},
+		{"env.HOME", "string"},
+		{"env[github.event_name]", "string"},
+		{"inputs.debug", "bool"},
+		{"inputs.unknown", "null"},
+		{"github.event_name.length", "null"},
+		{"matrix[0]", "{os: string}"},
+		{"matrix[0].os", "string"},
+		{"matrix.*.os", "array<string>"},
+		{"env.*", "array<string>"},
+		{"unknown.a.b", "any"},
+		{"null.a", "null"},
+		{"'abc'[0]", "null"},
+		{"github.run_number[0]", "null"},
+		{"github[1]", "null"},
+		{"env[true]", "null"},
+		{"fromJSON('{}')[1]", "null"},
+
+		{"github.run_number == 1", "bool"},
+		{"!github.event_name", "bool"},
+		{"github.event_name || 'push'", "string"},
+		{"inputs.debug && github.event_name", "any"},
+		{"github.run_number || github.event_name", "any"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := InferType(tt.input, ctx)
+			if err != nil {
+				t.Fatalf("InferType() error = %v", err)
+			}
+
+			if got.String() != tt.want {
+				t.Errorf("InferType() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInferType_Errors(t *testing.T) {
+	tests := []struct {
+		input   string
+		wantErr string
+	}{
+		{"fromJSON()", "error calling fromJSON: invalid number of arguments. expected 1, got 0"},
+		{"fromJSON('{')", "error calling fromJSON: could not parse JSON: unexpected end of JSON input"},
+		{"contians('a', 'b')", "undefined function contians, did you mean contains?"},
+		{"format('{0}', fromJOSN('a'))", "undefined function fromJOSN, did you mean fromJSON?"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := InferType(tt.input, nil)
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("InferType() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEvaluator_InferType(t *testing.T) {
+	e := NewEvaluator(WithFunctions(map[string]FunctionDefinition{"custom": {0, nil}}))
+	e.ExtendedFunctions = true
+	e.UnknownContextIsError = true
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"length('abc')", "number"},
+		{"trim(' a ')", "string"},
+		{"custom()", "any"},
+	}
+	for _, tt := range tests {
+		got, err := e.InferType(tt.input, nil)
+		if err != nil {
+			t.Fatalf("InferType(%q) error = %v", tt.input, err)
+		}
+
+		if got.String() != tt.want {
+			t.Errorf("InferType(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+
+	if _, err := e.InferType("unknown.a", nil); err == nil || err.Error() != "unknown context unknown" {
+		t.Errorf("InferType() error = %v, want unknown context error", err)
+	}
+}
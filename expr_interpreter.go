@@ -4,6 +4,8 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"sort"
+	"strconv"
 	"strings"
 
 	errs "github.com/pkg/errors"
@@ -13,7 +15,253 @@ import (
 
 type ContextData = map[string]interface{}
 
-func Evaluate(n actionlint.ExprNode, context ContextData) (*EvaluationResult, error) {
+// Status is the status of the current job as seen by the status check functions.
+type Status int
+
+const (
+	// StatusSuccess indicates that no previous step has failed or been cancelled.
+	StatusSuccess Status = iota
+	// StatusFailure indicates that a previous step has failed.
+	StatusFailure
+	// StatusCancelled indicates that the workflow run has been cancelled.
+	StatusCancelled
+)
+
+// Evaluator evaluates expressions. The zero value is ready to use. An Evaluator can be used by
+// multiple goroutines at the same time, as long as its fields are not modified.
+type Evaluator struct {
+	// Workspace is the directory file functions like hashFiles() resolve their patterns against, like
+	// GITHUB_WORKSPACE on GitHub. File functions fail if it is empty.
+	Workspace string
+
+	// Status is the job status used by success(), failure(), and cancelled(). Defaults to
+	// StatusSuccess.
+	Status Status
+
+	// MaskSecrets marks values read from the `secrets` context. Comparisons and functions use their
+	// actual value, so `contains(format('{0}', secrets.X), 'a')` or `fromJSON(secrets.X)` evaluate like
+	// without masking. Strings computed from secrets are masked as well: they are replaced by `***`,
+	// or contain `***` in place of each secret, when they are converted to strings with CoerceString,
+	// serialized with MarshalJSON, decoded with Decode, or appear in error messages. length() returns
+	// the length of the masked string. Numbers and booleans decoded by fromJSON() from a secret are not
+	// masked. The Value of results holding a
+	// secret is not a plain string.
+	MaskSecrets bool
+
+	// MaxJSONSize is the maximum size in bytes of the input accepted by fromJSON(). Larger inputs fail
+	// the evaluation instead of being decoded. If zero, the size is not limited.
+	MaxJSONSize int
+
+	// MaxStringLength is the maximum length in bytes of the strings built by format(), join(), and
+	// toJSON(). Longer results fail the evaluation. If zero, the length is not limited.
+	MaxStringLength int
+
+	// PreserveNumberPrecision makes fromJSON() keep the text of numbers as json.Number, instead of
+	// decoding them into float64 like GitHub does. toJSON() and conversions to strings return the
+	// original text, so large integers and decimals are not rounded. Comparisons still convert numbers
+	// to float64.
+	PreserveNumberPrecision bool
+
+	// RejectDuplicateJSONKeys makes fromJSON() fail for objects with duplicate keys, including keys
+	// differing only in case. By default the last value wins, like on GitHub.
+	RejectDuplicateJSONKeys bool
+
+	// Strict rejects expressions relying on implicit type conversions, which are otherwise applied like
+	// on GitHub. Comparing values of different types, for example `'abc' > 1`, or calling format() with
+	// a template that is not a string fails the evaluation. Comparisons with null are still allowed, so
+	// unset values can be checked with `== null`.
+	Strict bool
+
+	// ExtendedFunctions makes functions available that are not supported by GitHub: length() returns
+	// the number of characters of a string or elements of an array, trim() removes leading and trailing
+	// whitespace. They are disabled by default to stay compatible with GitHub.
+	ExtendedFunctions bool
+
+	// CaseSensitiveStrings compares strings case-sensitively in `==`, `!=`, contains(), startsWith(),
+	// and endsWith(). GitHub ignores the case, so this is meant for tools detecting case-dependent
+	// conditions.
+	CaseSensitiveStrings bool
+
+	// UnknownContextIsError fails evaluations referencing a context that is not one of KnownContexts,
+	// instead of evaluating it to null. Known contexts missing from the given Context are still null.
+	UnknownContextIsError bool
+
+	// MaxDepth is the maximum nesting depth of the syntax tree of evaluated expressions. Deeper
+	// expressions fail to parse, or fail the evaluation if they are passed to EvaluateNode already
+	// parsed. If zero, the depth is not limited.
+	MaxDepth int
+
+	// DisabledFunctions are the names of functions that fail the evaluation when called, for example
+	// hashFiles to forbid access to the file system. Names are case-insensitive.
+	DisabledFunctions []string
+
+	// Tracer is notified of the result of every subexpression evaluated. If nil, nothing is traced.
+	Tracer Tracer
+
+	// OnUntrustedInput is called with the path of every untrusted input read during an evaluation, like
+	// `github.event.issue.title`. Untrusted inputs are taken from UntrustedInputs.
+	OnUntrustedInput func(path string)
+
+	// UntrustedInputs are the inputs reported to OnUntrustedInput. Defaults to
+	// actionlint.BuiltinUntrustedInputs.
+	UntrustedInputs actionlint.UntrustedInputSearchRoots
+
+	// functions are only available to this evaluator, keyed by lowercase name. They take precedence over
+	// the registered functions.
+	functions map[string]funcDef
+}
+
+// Option configures an Evaluator created by NewEvaluator.
+type Option func(e *Evaluator)
+
+// NewEvaluator returns an Evaluator configured with the given options. Fields can still be set on the
+// returned Evaluator.
+func NewEvaluator(opts ...Option) *Evaluator {
+	e := &Evaluator{}
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	return e
+}
+
+// Evaluate parses and evaluates the given expression using the default evaluator.
+func Evaluate(expr string, context Context) (*EvaluationResult, error) {
+	return (&Evaluator{}).Evaluate(expr, context)
+}
+
+// EvaluateNode evaluates an already parsed expression using the default evaluator.
+func EvaluateNode(n actionlint.ExprNode, context Context) (*EvaluationResult, error) {
+	return (&Evaluator{}).EvaluateNode(n, context)
+}
+
+// Evaluate parses and evaluates the given expression, without the surrounding `${{ }}`. Parse
+// errors and any failure during evaluation are returned as errors. If context is nil, all contexts
+// are null, which is enough for expressions only calling functions on literals.
+func (e *Evaluator) Evaluate(expr string, context Context) (*EvaluationResult, error) {
+	n, err := parse(expr, e.MaxDepth)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.evaluateParsed(n, newEvaluation(context))
+}
+
+// EvaluateAll parses and evaluates the given expressions using the default evaluator. See
+// Evaluator.EvaluateAll.
+func EvaluateAll(exprs []string, context Context) ([]*EvaluationResult, []error) {
+	return (&Evaluator{}).EvaluateAll(exprs, context)
+}
+
+// EvaluateAll parses and evaluates each of the given expressions, without the surrounding `${{ }}`,
+// with the same context, for example all the conditions of a workflow. Contexts are only looked up
+// once for the whole batch. The result and error of exprs[i] are at index i, an expression that
+// fails to parse or evaluate has a nil result and does not affect the others.
+func (e *Evaluator) EvaluateAll(exprs []string, context Context) ([]*EvaluationResult, []error) {
+	results := make([]*EvaluationResult, len(exprs))
+	evalErrors := make([]error, len(exprs))
+
+	s := newEvaluation(context)
+	for i, expr := range exprs {
+		n, err := parse(expr, e.MaxDepth)
+		if err != nil {
+			evalErrors[i] = err
+			continue
+		}
+
+		results[i], evalErrors[i] = e.evaluateParsed(n, s)
+	}
+
+	return results, evalErrors
+}
+
+// evaluateParsed evaluates a parsed expression, turning panics into errors.
+func (e *Evaluator) evaluateParsed(n actionlint.ExprNode, s *evaluation) (result *EvaluationResult, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			result = nil
+			err = fmt.Errorf("could not evaluate expression: %v", r)
+		}
+	}()
+
+	return e.evaluate(n, s)
+}
+
+// parse parses an expression without the surrounding `${{ }}`. If maxDepth is not zero, expressions
+// nested deeper than maxDepth fail before comparisons are regrouped, which takes longer the deeper
+// they are.
+func parse(expr string, maxDepth int) (actionlint.ExprNode, error) {
+	// The lexer expects the closing braces of the expression
+	lexer := actionlint.NewExprLexer(expr + "}}")
+	parser := actionlint.NewExprParser()
+	n, perr := parser.Parse(lexer)
+	if perr != nil {
+		return nil, errs.Wrap(perr, "could not parse expression")
+	}
+
+	// The lexer stops at the first closing braces outside of string literals, anything after them
+	// would be silently ignored
+	if end := lexer.Offset() - len("}}"); end < len(expr) {
+		return nil, fmt.Errorf("could not parse expression: unexpected '}}' at column %d", end+1)
+	}
+
+	if maxDepth > 0 && exprDepth(n) > maxDepth {
+		return nil, fmt.Errorf("expression exceeds the maximum depth of %d", maxDepth)
+	}
+
+	return regroupComparisons(n, expr), nil
+}
+
+// exprDepth returns the nesting depth of the syntax tree n, a single node has depth 1.
+func exprDepth(n actionlint.ExprNode) int {
+	depth, deepest := 0, 0
+	actionlint.VisitExprNode(n, func(_, _ actionlint.ExprNode, entering bool) {
+		if !entering {
+			depth--
+			return
+		}
+
+		depth++
+		if depth > deepest {
+			deepest = depth
+		}
+	})
+
+	return deepest
+}
+
+// EvaluateNode evaluates an already parsed expression with the given context. Like for Evaluate,
+// panics during the evaluation are returned as errors.
+//
+// The tree is evaluated as it is. Trees parsed by actionlint.ExprParser directly group chains of
+// comparisons to the right, unlike GitHub and Evaluate, so `3 > 2 > 1` is evaluated as `3 > (2 > 1)`
+// and true. Use Evaluate to get GitHub's precedence, the source of the expression is needed to regroup
+// the comparisons.
+func (e *Evaluator) EvaluateNode(n actionlint.ExprNode, context Context) (*EvaluationResult, error) {
+	return e.evaluateParsed(n, newEvaluation(context))
+}
+
+func (e *Evaluator) evaluate(n actionlint.ExprNode, s *evaluation) (*EvaluationResult, error) {
+	s.depth++
+	defer func() { s.depth-- }()
+
+	if e.MaxDepth > 0 && s.depth > e.MaxDepth {
+		return nil, fmt.Errorf("expression exceeds the maximum depth of %d", e.MaxDepth)
+	}
+
+	result, err := e.evaluateNode(n, s)
+	if err == nil && e.OnUntrustedInput != nil {
+		e.checkUntrusted(n, s)
+	}
+
+	if err == nil && e.Tracer != nil {
+		e.Tracer.OnNode(n, result)
+	}
+
+	return result, err
+}
+
+func (e *Evaluator) evaluateNode(n actionlint.ExprNode, s *evaluation) (*EvaluationResult, error) {
 	switch tn := n.(type) {
 
 	//
@@ -31,27 +279,35 @@ func Evaluate(n actionlint.ExprNode, context ContextData) (*EvaluationResult, er
 	case *actionlint.BoolNode:
 		return &EvaluationResult{Value: tn.Value, Type: &actionlint.BoolType{}}, nil
 
+	case *actionlint.NullNode:
+		return &EvaluationResult{Value: nil, Type: &actionlint.NullType{}}, nil
+
 	//
 	// Context access
 	//
 	case *actionlint.VariableNode:
-		name := tn.Name
-		v, ok := context[name]
-		if !ok {
-			return nil, errors.New("unknown variable access: " + name)
+		if e.UnknownContextIsError && !isKnownContext(tn.Name) {
+			return nil, &UnknownContextError{Name: tn.Name, pos: tokenPos(tn.Token())}
 		}
 
+		v := s.lookup(tn.Name, e.MaskSecrets)
+
 		vt := getExprType(v)
 
 		return &EvaluationResult{Value: v, Type: vt}, nil
 
 	// Access to object via "."
 	case *actionlint.ObjectDerefNode:
-		result, err := Evaluate(tn.Receiver, context)
+		result, err := e.evaluate(tn.Receiver, s)
 		if err != nil {
 			return nil, errs.Wrap(err, "could not evaluate receiver")
 		}
 
+		// Property access on the result of an object filter is applied to every element
+		if at, ok := result.Type.(*actionlint.ArrayType); ok && at.Deref {
+			return filterProperty(result, tn.Property, s), nil
+		}
+
 		if _, ok := result.Type.(*actionlint.ObjectType); !ok {
 			return &EvaluationResult{nil, &actionlint.NullType{}}, nil
 		}
@@ -62,11 +318,8 @@ func Evaluate(n actionlint.ExprNode, context ContextData) (*EvaluationResult, er
 			return nil, errors.New("invalid result received for receiver")
 		}
 
-		property := tn.Property
-		v, ok := obj[property]
-		if !ok {
-			return nil, errors.New("unknown context access: " + property)
-		}
+		// Unknown properties evaluate to null
+		v := propertyValue(obj, tn.Property, s)
 
 		vt := getExprType(v)
 
@@ -74,36 +327,36 @@ func Evaluate(n actionlint.ExprNode, context ContextData) (*EvaluationResult, er
 
 	// Access to array of object via []
 	case *actionlint.IndexAccessNode:
-		idxResult, err := Evaluate(tn.Index, context)
+		idxResult, err := e.evaluate(tn.Index, s)
 		if err != nil {
 			return nil, errs.Wrap(err, "could not evalute index for index access")
 		}
 
-		objResult, err := Evaluate(tn.Operand, context)
+		objResult, err := e.evaluate(tn.Operand, s)
 		if err != nil {
 			return nil, errs.Wrap(err, "could not get operand for index access")
 		}
 
 		if _, ok := objResult.Type.(*actionlint.ArrayType); ok {
-			return arrayAccess(objResult, idxResult)
+			return arrayAccess(objResult, idxResult, s)
 		}
 
 		if _, ok := objResult.Type.(*actionlint.ObjectType); ok {
-			return objectAccess(objResult, idxResult)
+			return objectAccess(objResult, idxResult, s)
 		}
 
-		// break!
-		return nil, errors.New("invalid operand for index access")
+		// Like property access, indexes of anything but arrays and objects evaluate to null. Strings are
+		// not indexed by character, and missing intermediate objects do not fail the evaluation.
+		return &EvaluationResult{nil, &actionlint.NullType{}}, nil
 
 	// ArrayDeref is accessing an array with a wild-card, like `inputs.*.test`
 	case *actionlint.ArrayDerefNode:
-		// result, err := Evaluate(tn.Receiver, context)
-		// if err != nil {
-		// 	return nil, errs.Wrap(err, "could not evaluate receiver")
-		// }
+		result, err := e.evaluate(tn.Receiver, s)
+		if err != nil {
+			return nil, errs.Wrap(err, "could not evaluate receiver")
+		}
 
-		// return result, nil
-		panic("wildcard access not implemented")
+		return filter(result, s), nil
 
 	//
 	// Function call
@@ -112,7 +365,7 @@ func Evaluate(n actionlint.ExprNode, context ContextData) (*EvaluationResult, er
 		// Evaluate arguments
 		args := make([]*EvaluationResult, len(tn.Args))
 		for i, arg := range tn.Args {
-			a, err := Evaluate(arg, context)
+			a, err := e.evaluate(arg, s)
 			if err != nil {
 				return nil, err
 			}
@@ -120,153 +373,278 @@ func Evaluate(n actionlint.ExprNode, context ContextData) (*EvaluationResult, er
 			args[i] = a
 		}
 
-		return fcall(tn.Callee, args)
+		return e.fcall(tn, args, s)
 
 	//
 	// Unary Operators
 	//
 	case *actionlint.NotOpNode:
-		r, err := Evaluate(tn.Operand, context)
+		r, err := e.evaluate(tn.Operand, s)
 		if err != nil {
 			return nil, err
 		}
 
-		return &EvaluationResult{r.Falsy(), &actionlint.BoolType{}}, nil
+		return &EvaluationResult{!r.CoerceBool(), &actionlint.BoolType{}}, nil
 
 	//
 	// Binary Operators
 	//
 	case *actionlint.CompareOpNode:
-		left, err := Evaluate(tn.Left, context)
+		left, err := e.evaluate(tn.Left, s)
 		if err != nil {
 			return nil, err
 		}
-		right, err := Evaluate(tn.Right, context)
+		right, err := e.evaluate(tn.Right, s)
 		if err != nil {
 			return nil, err
 		}
 
+		if e.Strict {
+			if err := checkStrictComparison(tn, left, right); err != nil {
+				return nil, err
+			}
+		}
+
 		switch tn.Kind {
 		case actionlint.CompareOpNodeKindEq:
-			return &EvaluationResult{left.Equals(right), &actionlint.BoolType{}}, nil
+			return &EvaluationResult{left.equals(right, e.CaseSensitiveStrings), &actionlint.BoolType{}}, nil
 
 		case actionlint.CompareOpNodeKindNotEq:
-			return &EvaluationResult{!left.Equals(right), &actionlint.BoolType{}}, nil
+			return &EvaluationResult{!left.equals(right, e.CaseSensitiveStrings), &actionlint.BoolType{}}, nil
 
 		case actionlint.CompareOpNodeKindGreater:
 			return &EvaluationResult{left.GreaterThan(right), &actionlint.BoolType{}}, nil
 
 		case actionlint.CompareOpNodeKindGreaterEq:
-			return &EvaluationResult{
-				left.Equals(right) || left.GreaterThan(right),
-				&actionlint.BoolType{},
-			}, nil
+			return &EvaluationResult{left.GreaterThanOrEqual(right), &actionlint.BoolType{}}, nil
 
 		case actionlint.CompareOpNodeKindLess:
 			return &EvaluationResult{left.LessThan(right), &actionlint.BoolType{}}, nil
 
 		case actionlint.CompareOpNodeKindLessEq:
-			return &EvaluationResult{
-				left.Equals(right) || left.LessThan(right),
-				&actionlint.BoolType{},
-			}, nil
+			return &EvaluationResult{left.LessThanOrEqual(right), &actionlint.BoolType{}}, nil
 		}
 
 	case *actionlint.LogicalOpNode:
-		_, err := Evaluate(tn.Left, context)
-		if err != nil {
-			return nil, err
-		}
-		_, err = Evaluate(tn.Right, context)
+		// Logical operators return one of their operands and only evaluate the right operand if the
+		// left one does not decide the result.
+		left, err := e.evaluate(tn.Left, s)
 		if err != nil {
 			return nil, err
 		}
 
 		switch tn.Kind {
 		case actionlint.LogicalOpNodeKindAnd:
-			left, err := Evaluate(tn.Left, context)
-			if err != nil {
-				return nil, err
-			}
-
-			right, err := Evaluate(tn.Right, context)
-			if err != nil {
-				return nil, err
+			if !left.CoerceBool() {
+				return left, nil
 			}
 
-			return &EvaluationResult{left.Truthy() && right.Truthy(), &actionlint.BoolType{}}, nil
+			return e.evaluate(tn.Right, s)
 
 		case actionlint.LogicalOpNodeKindOr:
-			left, err := Evaluate(tn.Left, context)
-			if err != nil {
-				return nil, err
+			if left.CoerceBool() {
+				return left, nil
 			}
 
-			if left.Truthy() {
-				// No need to evaluate rhs
-				return &EvaluationResult{true, &actionlint.BoolType{}}, nil
-			}
-
-			right, err := Evaluate(tn.Right, context)
-			if err != nil {
-				return nil, err
-			}
-
-			return &EvaluationResult{right.Truthy(), &actionlint.BoolType{}}, nil
+			return e.evaluate(tn.Right, s)
 		}
 	}
 
 	panic("unknown node")
 }
 
-func fcall(name string, args []*EvaluationResult) (*EvaluationResult, error) {
+// checkStrictComparison returns an error if the operands of the comparison would be converted to
+// another type to compare them.
+func checkStrictComparison(n *actionlint.CompareOpNode, left, right *EvaluationResult) error {
+	lk, rk := kindName(left.Type), kindName(right.Type)
+
+	equality := n.Kind == actionlint.CompareOpNodeKindEq || n.Kind == actionlint.CompareOpNodeKindNotEq
+	if equality && (lk == "null" || rk == "null") {
+		return nil
+	}
+
+	if lk != rk {
+		return fmt.Errorf("strict mode: cannot compare %s with %s", lk, rk)
+	}
+
+	if !equality && lk != "number" {
+		return fmt.Errorf("strict mode: cannot order values of type %s", lk)
+	}
+
+	return nil
+}
+
+func (e *Evaluator) fcall(n *actionlint.FuncCallNode, args []*EvaluationResult, s *evaluation) (*EvaluationResult, error) {
+	name := n.Callee
+	pos := tokenPos(n.Token())
+
 	// Expression function names are case-insensitive.
-	funcDef, ok := functions[strings.ToLower(name)]
+	key := strings.ToLower(name)
+	funcDef, ok := e.lookupFunction(key)
 	if !ok {
-		return nil, errors.New("unknown function: " + name)
+		return nil, &UndefinedFunctionError{Name: name, Suggestion: e.suggestFunction(key), pos: pos}
+	}
+
+	if e.disabledFunction(key) {
+		return nil, &DisabledFunctionError{Name: name, pos: pos}
+	}
+
+	if err := funcDef.checkArgs(len(args)); err != nil {
+		return nil, &EvaluationError{Func: name, Err: err, pos: pos}
+	}
+
+	// Built-in functions always return the same result for the same arguments during an evaluation
+	callKey, canMemoize := memoizeKey(key, args)
+	canMemoize = canMemoize && !funcDef.volatile
+	if canMemoize {
+		if result, ok := s.calls[callKey]; ok {
+			return copyResult(result), nil
+		}
 	}
 
-	if funcDef.argsCount >= 0 {
-		if funcDef.argsCount != len(args) {
-			return nil, errors.New(fmt.Sprintf("invalid number of arguments. expected %d, got %d", funcDef.argsCount, len(args)))
+	result, err := callFunction(e, funcDef, args)
+	if err != nil {
+		return nil, &EvaluationError{Func: name, Err: err, pos: pos}
+	}
+
+	if canMemoize {
+		s.calls[callKey] = result
+	}
+
+	return result, nil
+}
+
+// callFunction calls the given function, returning panics of custom functions as errors.
+func callFunction(e *Evaluator, funcDef funcDef, args []*EvaluationResult) (result *EvaluationResult, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			result = nil
+			err = fmt.Errorf("function panicked: %v", r)
 		}
-	} else {
-		if int(math.Abs(float64(funcDef.argsCount))) > len(args) {
-			return nil, errors.New(fmt.Sprintf("invalid number of arguments. expected at least %d, got %d", funcDef.argsCount, len(args)))
+	}()
+
+	result, err = funcDef.call(e, args...)
+	if err != nil && e.MaskSecrets {
+		// Functions see the actual values of secrets, which must not end up in error messages
+		var secrets []string
+		for _, arg := range args {
+			secrets = secretValues(secrets, arg.Value)
 		}
+
+		err = maskError(err, secrets)
 	}
 
-	return funcDef.call(args...), nil
+	return result, err
 }
 
-func arrayAccess(array *EvaluationResult, idx *EvaluationResult) (*EvaluationResult, error) {
-	// TODO: Assert type of array
-	arrayT := array.Value.([]interface{})
+func arrayAccess(array *EvaluationResult, idx *EvaluationResult, s *evaluation) (*EvaluationResult, error) {
+	arrayT, ok := array.Value.([]interface{})
+	if !ok {
+		return nil, errors.New("invalid array received for index access")
+	}
 
-	// Check for number index
+	// Indexes are converted to numbers and fractional indexes are truncated. Negative and out of range
+	// indexes result in null.
 	numberIdx := convertToNumber(idx.Value)
-	if !math.IsNaN(numberIdx) && numberIdx >= 0.0 {
-		idxInt := int(numberIdx)
+	if math.IsNaN(numberIdx) || numberIdx < 0.0 || numberIdx >= float64(len(arrayT)) {
+		return &EvaluationResult{nil, &actionlint.NullType{}}, nil
+	}
+
+	i := int(numberIdx)
+	v := s.distinctEmptyArray(arrayT, strconv.Itoa(i), arrayT[i])
+	return &EvaluationResult{v, getExprType(v)}, nil
+}
+
+func objectAccess(obj *EvaluationResult, idx *EvaluationResult, s *evaluation) (*EvaluationResult, error) {
+	objT, ok := obj.Value.(ContextData)
+	if !ok {
+		return nil, errors.New("invalid object received for index access")
+	}
+
+	// Only strings are property names, other indexes are not converted and result in null like on GitHub
+	if _, ok := idx.Type.(*actionlint.StringType); !ok {
+		return &EvaluationResult{nil, &actionlint.NullType{}}, nil
+	}
+
+	v := propertyValue(objT, toString(idx.Value), s)
+
+	return &EvaluationResult{v, getExprType(v)}, nil
+}
+
+// propertyValue returns the value of the given property of obj, or null if it does not have it.
+func propertyValue(obj ContextData, property string, s *evaluation) interface{} {
+	k, ok := matchKey(obj, property)
+	if !ok {
+		return nil
+	}
+
+	return s.distinctEmptyArray(obj, k, obj[k])
+}
 
-		if idxInt < 0 || idxInt >= len(arrayT) {
-			return nil, errors.New("index out of range")
+// filter applies the object filter `.*` to the given result. Arrays yield their elements, objects their
+// values, everything else an empty array. The result is marked as filtered, so that subsequent property
+// accesses are applied to each element.
+func filter(receiver *EvaluationResult, s *evaluation) *EvaluationResult {
+	values := newArray(0)
+
+	switch v := receiver.Value.(type) {
+	case []interface{}:
+		if at, ok := receiver.Type.(*actionlint.ArrayType); ok && at.Deref {
+			// Filtering the result of another filter flattens the elements
+			for _, item := range v {
+				values = append(values, filterValues(item, s)...)
+			}
+		} else {
+			values = append(values, filterValues(v, s)...)
 		}
 
-		v := arrayT[idxInt]
-		return &EvaluationResult{v, getExprType(v)}, nil
+	case ContextData:
+		values = append(values, filterValues(v, s)...)
 	}
 
-	return &EvaluationResult{nil, &actionlint.AnyType{}}, nil
+	return &EvaluationResult{values, &actionlint.ArrayType{Elem: &actionlint.AnyType{}, Deref: true}}
 }
 
-func objectAccess(obj *EvaluationResult, idx *EvaluationResult) (*EvaluationResult, error) {
-	// Index has to be string
-	if _, ok := idx.Type.(*actionlint.StringType); !ok {
-		return nil, errors.New("index must be string")
+// filterValues returns the elements of an array or the values of an object, ordered by key.
+func filterValues(v interface{}, s *evaluation) []interface{} {
+	switch vt := v.(type) {
+	case []interface{}:
+		values := make([]interface{}, len(vt))
+		for i, item := range vt {
+			values[i] = s.distinctEmptyArray(vt, strconv.Itoa(i), item)
+		}
+
+		return values
+
+	case ContextData:
+		keys := make([]string, 0, len(vt))
+		for k := range vt {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		values := make([]interface{}, len(keys))
+		for i, k := range keys {
+			values[i] = s.distinctEmptyArray(vt, k, vt[k])
+		}
+
+		return values
 	}
 
-	key := idx.Value.(string)
-	v := obj.Value.(ContextData)[key]
+	return nil
+}
 
-	return &EvaluationResult{v, getExprType(v)}, nil
+// filterProperty accesses the given property on every element of a filtered array. Elements that
+// are not objects or do not have the property yield null.
+func filterProperty(filtered *EvaluationResult, property string, s *evaluation) *EvaluationResult {
+	items := filtered.Value.([]interface{})
+
+	values := newArray(len(items))
+	for i, item := range items {
+		if obj, ok := item.(ContextData); ok {
+			values[i] = propertyValue(obj, property, s)
+		}
+	}
+
+	return &EvaluationResult{values, &actionlint.ArrayType{Elem: &actionlint.AnyType{}, Deref: true}}
 }
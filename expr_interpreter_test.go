@@ -1,7 +1,11 @@
 package expr
 
 import (
+	"errors"
+	"fmt"
+	"math"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/rhysd/actionlint"
@@ -49,6 +53,61 @@ func Test_Evaluate(t *testing.T) {
 			input: "0.0",
 			want:  &EvaluationResult{Value: float64(0.0), Type: &actionlint.NumberType{}},
 		},
+		{
+			name:  "hex int",
+			input: "0xFF",
+			want:  &EvaluationResult{Value: float64(255), Type: &actionlint.NumberType{}},
+		},
+		{
+			name:  "hex int lowercase",
+			input: "0xff",
+			want:  &EvaluationResult{Value: float64(255), Type: &actionlint.NumberType{}},
+		},
+		{
+			name:  "negative hex int",
+			input: "-0x10",
+			want:  &EvaluationResult{Value: float64(-16), Type: &actionlint.NumberType{}},
+		},
+		{
+			name:  "exponent",
+			input: "1e3",
+			want:  &EvaluationResult{Value: float64(1000), Type: &actionlint.NumberType{}},
+		},
+		{
+			name:  "exponent uppercase",
+			input: "1E3",
+			want:  &EvaluationResult{Value: float64(1000), Type: &actionlint.NumberType{}},
+		},
+		{
+			name:  "negative exponent",
+			input: "1.5e-3",
+			want:  &EvaluationResult{Value: float64(0.0015), Type: &actionlint.NumberType{}},
+		},
+		{
+			name:  "negative number with exponent",
+			input: "-1e3",
+			want:  &EvaluationResult{Value: float64(-1000), Type: &actionlint.NumberType{}},
+		},
+		{
+			name:  "hex int comparison",
+			input: "0xFF == 255",
+			want:  &EvaluationResult{Value: true, Type: &actionlint.BoolType{}},
+		},
+		{
+			name:  "exponent comparison",
+			input: "1e3 == 1000",
+			want:  &EvaluationResult{Value: true, Type: &actionlint.BoolType{}},
+		},
+		{
+			name:  "hex int and string",
+			input: "0xff == '255'",
+			want:  &EvaluationResult{Value: true, Type: &actionlint.BoolType{}},
+		},
+		{
+			name:  "exponent and hex string",
+			input: "1e1 == '0xa'",
+			want:  &EvaluationResult{Value: true, Type: &actionlint.BoolType{}},
+		},
 		{
 			name:  "bool true",
 			input: "true",
@@ -59,6 +118,11 @@ func Test_Evaluate(t *testing.T) {
 			input: "false",
 			want:  &EvaluationResult{Value: false, Type: &actionlint.BoolType{}},
 		},
+		{
+			name:  "null",
+			input: "null",
+			want:  &EvaluationResult{Value: nil, Type: &actionlint.NullType{}},
+		},
 		{
 			name:  "neg operator",
 			input: "!false",
@@ -69,6 +133,96 @@ func Test_Evaluate(t *testing.T) {
 			input: "!true",
 			want:  &EvaluationResult{Value: false, Type: &actionlint.BoolType{}},
 		},
+		{
+			name:  "neg operator - null",
+			input: "!null",
+			want:  &EvaluationResult{Value: true, Type: &actionlint.BoolType{}},
+		},
+		{
+			name:  "neg operator - empty string",
+			input: "!''",
+			want:  &EvaluationResult{Value: true, Type: &actionlint.BoolType{}},
+		},
+		{
+			name:  "neg operator - string",
+			input: "!'x'",
+			want:  &EvaluationResult{Value: false, Type: &actionlint.BoolType{}},
+		},
+		{
+			name:  "neg operator - zero",
+			input: "!0",
+			want:  &EvaluationResult{Value: true, Type: &actionlint.BoolType{}},
+		},
+		{
+			name:  "neg operator - number",
+			input: "!1",
+			want:  &EvaluationResult{Value: false, Type: &actionlint.BoolType{}},
+		},
+		{
+			name:  "neg operator - object",
+			input: "!fromJSON('{}')",
+			want:  &EvaluationResult{Value: false, Type: &actionlint.BoolType{}},
+		},
+		{
+			name:  "neg operator - function call",
+			input: "!success()",
+			want:  &EvaluationResult{Value: false, Type: &actionlint.BoolType{}},
+		},
+		{
+			name:  "neg operator - startsWith",
+			input: "!startsWith('abc', 'x')",
+			want:  &EvaluationResult{Value: true, Type: &actionlint.BoolType{}},
+		},
+		{
+			name:  "neg operator - contains",
+			input: "!contains('abc', 'B')",
+			want:  &EvaluationResult{Value: false, Type: &actionlint.BoolType{}},
+		},
+		{
+			name:  "neg operator - contains null",
+			input: "!contains(null, 'x')",
+			want:  &EvaluationResult{Value: true, Type: &actionlint.BoolType{}},
+		},
+		{
+			name:  "neg operator - whitespace",
+			input: "!  endsWith('abc', 'c')",
+			want:  &EvaluationResult{Value: false, Type: &actionlint.BoolType{}},
+		},
+		{
+			name:  "neg operator - fromJSON",
+			input: "!fromJSON('false')",
+			want:  &EvaluationResult{Value: true, Type: &actionlint.BoolType{}},
+		},
+		{
+			name:  "neg operator - format",
+			input: "!format('')",
+			want:  &EvaluationResult{Value: true, Type: &actionlint.BoolType{}},
+		},
+		{
+			name:  "neg operator - join empty array",
+			input: "!join(fromJSON('[]'))",
+			want:  &EvaluationResult{Value: true, Type: &actionlint.BoolType{}},
+		},
+		{
+			name:  "neg operator - toJSON null",
+			input: "!toJSON(null)",
+			want:  &EvaluationResult{Value: false, Type: &actionlint.BoolType{}},
+		},
+		{
+			name:  "neg operator - double function call",
+			input: "!!contains('abc', 'b')",
+			want:  &EvaluationResult{Value: true, Type: &actionlint.BoolType{}},
+		},
+		{
+			name:  "neg operator - function call comparison",
+			input: "!startsWith('abc', 'a') == false",
+			want:  &EvaluationResult{Value: true, Type: &actionlint.BoolType{}},
+		},
+		{
+			name:  "neg operator - double",
+			input: "!!'x'",
+			want:  &EvaluationResult{Value: true, Type: &actionlint.BoolType{}},
+		},
 		{
 			name:    "context access",
 			input:   "input",
@@ -93,15 +247,70 @@ func Test_Evaluate(t *testing.T) {
 			context: map[string]interface{}{"input": map[string]interface{}{"test": []interface{}{float64(23), float64(42)}}},
 			want:    &EvaluationResult{Value: float64(42), Type: &actionlint.NumberType{}},
 		},
-		// {
-		// 	name:  "context access - wildcard",
-		// 	input: "input.*.foo",
-		// 	context: map[string]interface{}{"input": map[string]interface{}{
-		// 		"test":  map[string]interface{}{"foo": float64(32)},
-		// 		"test2": map[string]interface{}{"foo": float64(42)},
-		// 	}},
-		// 	want: &EvaluationResult{Value: []float64{32, 42}, Type: &actionlint.ArrayType{}},
-		// },
+		{
+			name:  "context access - array access out of range",
+			input: "fromJSON('[1,2,3]')[5]",
+			want:  &EvaluationResult{Value: nil, Type: &actionlint.NullType{}},
+		},
+		{
+			name:  "context access - array access negative index",
+			input: "fromJSON('[1,2,3]')[-1]",
+			want:  &EvaluationResult{Value: nil, Type: &actionlint.NullType{}},
+		},
+		{
+			name:  "context access - array access fractional index",
+			input: "fromJSON('[1,2,3]')[1.7]",
+			want:  &EvaluationResult{Value: float64(2), Type: &actionlint.NumberType{}},
+		},
+		{
+			name:  "context access - array access string index",
+			input: "fromJSON('[1,2,3]')['2']",
+			want:  &EvaluationResult{Value: float64(3), Type: &actionlint.NumberType{}},
+		},
+		{
+			name:  "context access - array access non-numeric index",
+			input: "fromJSON('[1,2,3]')['a']",
+			want:  &EvaluationResult{Value: nil, Type: &actionlint.NullType{}},
+		},
+		{
+			name:  "context access - object access missing key",
+			input: "fromJSON('{\"a\":1}')['b']",
+			want:  &EvaluationResult{Value: nil, Type: &actionlint.NullType{}},
+		},
+		{
+			name:  "context access - wildcard",
+			input: "input.*.foo",
+			context: map[string]interface{}{"input": map[string]interface{}{
+				"test":  map[string]interface{}{"foo": float64(32)},
+				"test2": map[string]interface{}{"foo": float64(42)},
+			}},
+			want: &EvaluationResult{Value: []interface{}{float64(32), float64(42)}, Type: &actionlint.ArrayType{Elem: &actionlint.AnyType{}, Deref: true}},
+		},
+		{
+			name:  "context access - wildcard on array",
+			input: "fromJSON('[{\"id\": 1}, {\"id\": 2}, {\"name\": \"x\"}]').*.id",
+			want:  &EvaluationResult{Value: []interface{}{float64(1), float64(2), nil}, Type: &actionlint.ArrayType{Elem: &actionlint.AnyType{}, Deref: true}},
+		},
+		{
+			name:  "context access - wildcard nested",
+			input: "fromJSON('[{\"author\": {\"email\": \"a@b\"}}, {\"author\": {\"email\": \"c@d\"}}]').*.author.email",
+			want:  &EvaluationResult{Value: []interface{}{"a@b", "c@d"}, Type: &actionlint.ArrayType{Elem: &actionlint.AnyType{}, Deref: true}},
+		},
+		{
+			name:  "context access - wildcard only",
+			input: "fromJSON('[1, 2]').*",
+			want:  &EvaluationResult{Value: []interface{}{float64(1), float64(2)}, Type: &actionlint.ArrayType{Elem: &actionlint.AnyType{}, Deref: true}},
+		},
+		{
+			name:  "context access - wildcard on primitive",
+			input: "fromJSON('1').*",
+			want:  &EvaluationResult{Value: []interface{}{}, Type: &actionlint.ArrayType{Elem: &actionlint.AnyType{}, Deref: true}},
+		},
+		{
+			name:  "context access - wildcard twice",
+			input: "fromJSON('{\"a\": {\"x\": {\"id\": 1}}, \"b\": {\"y\": {\"id\": 2}}}').*.*.id",
+			want:  &EvaluationResult{Value: []interface{}{float64(1), float64(2)}, Type: &actionlint.ArrayType{Elem: &actionlint.AnyType{}, Deref: true}},
+		},
 		{
 			name:  "comparison eq - equal strings",
 			input: "'test' == 'test'",
@@ -157,6 +366,37 @@ func Test_Evaluate(t *testing.T) {
 			input: "12 <= 12",
 			want:  &EvaluationResult{Value: true, Type: &actionlint.BoolType{}},
 		},
+		{
+			name:  "comparison lt - non-numeric string",
+			input: "'abc' < 1",
+			want:  &EvaluationResult{Value: false, Type: &actionlint.BoolType{}},
+		},
+		{
+			name:  "comparison gteq - non-numeric string",
+			input: "'abc' >= 'abc'",
+			want:  &EvaluationResult{Value: false, Type: &actionlint.BoolType{}},
+		},
+		{
+			name:  "comparison gt - numeric strings",
+			input: "'10' > '9'",
+			want:  &EvaluationResult{Value: true, Type: &actionlint.BoolType{}},
+		},
+		{
+			name:  "comparison lteq - bool",
+			input: "false <= true",
+			want:  &EvaluationResult{Value: true, Type: &actionlint.BoolType{}},
+		},
+		{
+			name:  "comparison gt - null",
+			input: "1 > null",
+			want:  &EvaluationResult{Value: true, Type: &actionlint.BoolType{}},
+		},
+		{
+			name:    "comparison gt - object",
+			input:   "input > 0",
+			context: map[string]interface{}{"input": map[string]interface{}{}},
+			want:    &EvaluationResult{Value: false, Type: &actionlint.BoolType{}},
+		},
 		{
 			name:  "comparison eq - bool not equal",
 			input: "true == false",
@@ -182,6 +422,32 @@ func Test_Evaluate(t *testing.T) {
 			input: "2 == '2'",
 			want:  &EvaluationResult{Value: true, Type: &actionlint.BoolType{}},
 		},
+		{
+			name:  "comparison eq - equal bool number",
+			input: "true == 1",
+			want:  &EvaluationResult{Value: true, Type: &actionlint.BoolType{}},
+		},
+		{
+			name:  "comparison eq - strings case-insensitive",
+			input: "'ABC' == 'abc'",
+			want:  &EvaluationResult{Value: true, Type: &actionlint.BoolType{}},
+		},
+		{
+			name:  "comparison eq - null number",
+			input: "null == 0",
+			want:  &EvaluationResult{Value: true, Type: &actionlint.BoolType{}},
+		},
+		{
+			name:    "comparison eq - same object",
+			input:   "input == input",
+			context: map[string]interface{}{"input": map[string]interface{}{}},
+			want:    &EvaluationResult{Value: true, Type: &actionlint.BoolType{}},
+		},
+		{
+			name:  "comparison neq - null and empty string",
+			input: "null != ''",
+			want:  &EvaluationResult{Value: false, Type: &actionlint.BoolType{}},
+		},
 		{
 			name:  "logical or - true",
 			input: "true || false",
@@ -212,6 +478,53 @@ func Test_Evaluate(t *testing.T) {
 			input: "(1 == 2) || (1 == 1)",
 			want:  &EvaluationResult{Value: true, Type: &actionlint.BoolType{}},
 		},
+		{
+			name:  "logical or - returns left operand",
+			input: "'first' || 'second'",
+			want:  &EvaluationResult{Value: "first", Type: &actionlint.StringType{}},
+		},
+		{
+			name:  "logical or - returns right operand",
+			input: "'' || 'default'",
+			want:  &EvaluationResult{Value: "default", Type: &actionlint.StringType{}},
+		},
+		{
+			name:  "logical or - returns first truthy operand",
+			input: "'' || 0 || 'last'",
+			want:  &EvaluationResult{Value: "last", Type: &actionlint.StringType{}},
+		},
+		{
+			name:  "logical or - returns last operand if none is truthy",
+			input: "'' || null || 0",
+			want:  &EvaluationResult{Value: float64(0), Type: &actionlint.NumberType{}},
+		},
+		{
+			name:    "logical or - default for missing input",
+			input:   "inputs.name || 'default'",
+			context: map[string]interface{}{"inputs": map[string]interface{}{}},
+			want:    &EvaluationResult{Value: "default", Type: &actionlint.StringType{}},
+		},
+		{
+			name:  "logical and - returns left operand",
+			input: "0 && 'x'",
+			want:  &EvaluationResult{Value: float64(0), Type: &actionlint.NumberType{}},
+		},
+		{
+			name:    "logical and - returns right operand",
+			input:   "input && input.field",
+			context: map[string]interface{}{"input": map[string]interface{}{"field": "value"}},
+			want:    &EvaluationResult{Value: "value", Type: &actionlint.StringType{}},
+		},
+		{
+			name:  "logical and - short-circuit",
+			input: "false && fromJSON('{')",
+			want:  &EvaluationResult{Value: false, Type: &actionlint.BoolType{}},
+		},
+		{
+			name:  "logical or - short-circuit",
+			input: "true || unknown.field",
+			want:  &EvaluationResult{Value: true, Type: &actionlint.BoolType{}},
+		},
 		{
 			name:  "fcall - startsWith",
 			input: "startsWith('test', 'tE')",
@@ -249,12 +562,136 @@ func Test_Evaluate(t *testing.T) {
 			context: map[string]interface{}{"inputs": map[string]interface{}{"values": []interface{}{"42", "1"}}},
 			want:    &EvaluationResult{Value: "42:1", Type: &actionlint.StringType{}},
 		},
+		{
+			name:  "fcall - join - null separator",
+			input: "join(fromJSON('[\"a\",\"b\"]'), null)",
+			want:  &EvaluationResult{Value: "ab", Type: &actionlint.StringType{}},
+		},
+		{
+			name:  "fcall - join - object",
+			input: "join(fromJSON('{\"a\":1}'))",
+			want:  &EvaluationResult{Value: "Object", Type: &actionlint.StringType{}},
+		},
+		{
+			name:  "fcall - join - empty array",
+			input: "join(fromJSON('[]'))",
+			want:  &EvaluationResult{Value: "", Type: &actionlint.StringType{}},
+		},
+		{
+			name:  "fcall - join - empty array with separator",
+			input: "join(fromJSON('[]'), '-')",
+			want:  &EvaluationResult{Value: "", Type: &actionlint.StringType{}},
+		},
+		{
+			name:  "fcall - join - single element",
+			input: "join(fromJSON('[\"a\"]'))",
+			want:  &EvaluationResult{Value: "a", Type: &actionlint.StringType{}},
+		},
+		{
+			name:  "fcall - join - single element with separator",
+			input: "join(fromJSON('[\"a\"]'), '-')",
+			want:  &EvaluationResult{Value: "a", Type: &actionlint.StringType{}},
+		},
+		{
+			name:  "fcall - join - empty strings",
+			input: "join(fromJSON('[\"\", \"\"]'), '-')",
+			want:  &EvaluationResult{Value: "-", Type: &actionlint.StringType{}},
+		},
+		{
+			name:  "fcall - join - null elements",
+			input: "join(fromJSON('[null, 1, null]'))",
+			want:  &EvaluationResult{Value: ",1,", Type: &actionlint.StringType{}},
+		},
+		{
+			name:  "fcall - join - empty string",
+			input: "join('')",
+			want:  &EvaluationResult{Value: "", Type: &actionlint.StringType{}},
+		},
+		{
+			name:  "fcall - join - empty separator",
+			input: "join(fromJSON('[\"a\", \"b\"]'), '')",
+			want:  &EvaluationResult{Value: "ab", Type: &actionlint.StringType{}},
+		},
+		{
+			name:  "fcall - join - null",
+			input: "join(null)",
+			want:  &EvaluationResult{Value: "", Type: &actionlint.StringType{}},
+		},
+		{
+			name:  "fcall - join - number",
+			input: "join(fromJSON('5'))",
+			want:  &EvaluationResult{Value: "5", Type: &actionlint.StringType{}},
+		},
+		{
+			name:  "fcall - join - number with separator",
+			input: "join(1.5, '-')",
+			want:  &EvaluationResult{Value: "1.5", Type: &actionlint.StringType{}},
+		},
+		{
+			name:  "fcall - join - boolean",
+			input: "join(true)",
+			want:  &EvaluationResult{Value: "true", Type: &actionlint.StringType{}},
+		},
+		{
+			name:  "fcall - format",
+			input: "format('{0}-{1}', 'a', 'b')",
+			want:  &EvaluationResult{Value: "a-b", Type: &actionlint.StringType{}},
+		},
+		{
+			name:  "fcall - format - escaped braces",
+			input: "format('{{literal}} {0}', 1)",
+			want:  &EvaluationResult{Value: "{literal} 1", Type: &actionlint.StringType{}},
+		},
+		{
+			name:  "fcall - format - no placeholders",
+			input: "format('test')",
+			want:  &EvaluationResult{Value: "test", Type: &actionlint.StringType{}},
+		},
+		{
+			name:  "fcall - toJSON - object",
+			input: "toJSON(fromJSON('{\"a\":1}'))",
+			want:  &EvaluationResult{Value: "{\n  \"a\": 1\n}", Type: &actionlint.StringType{}},
+		},
+		{
+			name:    "fcall - toJSON - array",
+			input:   "toJSON(inputs.values)",
+			context: map[string]interface{}{"inputs": map[string]interface{}{"values": []interface{}{"a", float64(1.5), true, nil}}},
+			want:    &EvaluationResult{Value: "[\n  \"a\",\n  1.5,\n  true,\n  null\n]", Type: &actionlint.StringType{}},
+		},
+		{
+			name:  "fcall - toJSON - string",
+			input: "toJSON('a<b')",
+			want:  &EvaluationResult{Value: "\"a<b\"", Type: &actionlint.StringType{}},
+		},
+		{
+			name:  "fcall - toJSON - number",
+			input: "toJSON(3.0)",
+			want:  &EvaluationResult{Value: "3", Type: &actionlint.StringType{}},
+		},
+		{
+			name:  "fcall - toJSON - bool",
+			input: "tojson(false)",
+			want:  &EvaluationResult{Value: "false", Type: &actionlint.StringType{}},
+		},
+		{
+			name:  "fcall - toJSON - null",
+			input: "toJSON(null)",
+			want:  &EvaluationResult{Value: "null", Type: &actionlint.StringType{}},
+		},
+		{
+			name:  "fcall - success - default status",
+			input: "success()",
+			want:  &EvaluationResult{Value: true, Type: &actionlint.BoolType{}},
+		},
 		{
 			name:  "fcall - fromJson",
 			input: "fromJson('{\"foo\": 42}')",
 			want: &EvaluationResult{Value: ContextData{
 				"foo": float64(42),
-			}, Type: &actionlint.ObjectType{}},
+			}, Type: &actionlint.ObjectType{
+				Props:  map[string]actionlint.ExprType{"foo": &actionlint.NumberType{}},
+				Mapped: &actionlint.AnyType{},
+			}},
 		},
 
 		{
@@ -263,9 +700,66 @@ func Test_Evaluate(t *testing.T) {
 			want:  &EvaluationResult{Value: float64(42), Type: &actionlint.NumberType{}},
 		},
 		{
-			name:  "fcall - fromJson - empty string",
-			input: "fromJson('')",
-			want:  &EvaluationResult{Value: ContextData{}, Type: &actionlint.ObjectType{}},
+			name:  "fcall - fromJson - array",
+			input: "fromJson('[1, \"a\"]')",
+			want: &EvaluationResult{
+				Value: []interface{}{float64(1), "a"},
+				Type:  &actionlint.ArrayType{Elem: &actionlint.AnyType{}},
+			},
+		},
+		{
+			name:  "fcall - fromJson - typed array",
+			input: "fromJson('[1, 2, 3]')",
+			want: &EvaluationResult{
+				Value: []interface{}{float64(1), float64(2), float64(3)},
+				Type:  &actionlint.ArrayType{Elem: &actionlint.NumberType{}},
+			},
+		},
+		{
+			name:  "fcall - fromJson - nested",
+			input: "fromJson('{\"a\": [{\"b\": true}]}')",
+			want: &EvaluationResult{
+				Value: ContextData{"a": []interface{}{ContextData{"b": true}}},
+				Type: &actionlint.ObjectType{
+					Props: map[string]actionlint.ExprType{
+						"a": &actionlint.ArrayType{Elem: &actionlint.ObjectType{
+							Props:  map[string]actionlint.ExprType{"b": &actionlint.BoolType{}},
+							Mapped: &actionlint.AnyType{},
+						}},
+					},
+					Mapped: &actionlint.AnyType{},
+				},
+			},
+		},
+		{
+			name:  "fcall - fromJson - number",
+			input: "fromJson('42')",
+			want:  &EvaluationResult{Value: float64(42), Type: &actionlint.NumberType{}},
+		},
+		{
+			name:  "fcall - fromJson - null",
+			input: "fromJson('null')",
+			want:  &EvaluationResult{Value: nil, Type: &actionlint.NullType{}},
+		},
+		{
+			name:  "fcall - fromJson - null equals empty string",
+			input: "fromJson('null') == ''",
+			want:  &EvaluationResult{Value: true, Type: &actionlint.BoolType{}},
+		},
+		{
+			name:  "fcall - fromJson - null equals zero",
+			input: "fromJson('null') == 0",
+			want:  &EvaluationResult{Value: true, Type: &actionlint.BoolType{}},
+		},
+		{
+			name:  "fcall - fromJson - null as string",
+			input: "format('[{0}]', fromJson('null'))",
+			want:  &EvaluationResult{Value: "[]", Type: &actionlint.StringType{}},
+		},
+		{
+			name:  "fcall - fromJson - null property",
+			input: "fromJson('{\"a\": null}').a == null",
+			want:  &EvaluationResult{Value: true, Type: &actionlint.BoolType{}},
 		},
 	}
 	for _, tt := range tests {
@@ -277,7 +771,7 @@ func Test_Evaluate(t *testing.T) {
 				t.Fatal(perr.Error())
 			}
 
-			got, err := Evaluate(n, tt.context)
+			got, err := EvaluateNode(n, MapContext(tt.context))
 			if err != nil {
 				t.Errorf("Evaluate() error = %v", err)
 			} else if !reflect.DeepEqual(got, tt.want) {
@@ -286,3 +780,859 @@ func Test_Evaluate(t *testing.T) {
 		})
 	}
 }
+
+func Test_Evaluate_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		wantFunc string
+	}{
+		{"fromJSON - invalid json", "fromJSON('{')", "fromJSON"},
+		{"fromJSON - trailing data", "fromJSON('{}}')", "fromJSON"},
+		{"fromJSON - empty string", "fromJSON('')", "fromJSON"},
+		{"fromJSON - whitespace", "fromJSON(' \n ')", "fromJSON"},
+		{"fromJSON - null", "fromJSON(null)", "fromJSON"},
+		{"format - index out of range", "format('{1}', 'a')", "format"},
+		{"format - signed placeholder", "format('{-0}', 1)", "format"},
+		{"format - positive placeholder", "format('{+0}', 1)", "format"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Evaluate(tt.input, nil)
+			var evalErr *EvaluationError
+			if !errors.As(err, &evalErr) {
+				t.Fatalf("Evaluate() error = %v, want EvaluationError", err)
+			}
+
+			if evalErr.Func != tt.wantFunc {
+				t.Errorf("EvaluationError.Func = %v, want %v", evalErr.Func, tt.wantFunc)
+			}
+		})
+	}
+}
+
+func Test_Evaluate_InvalidArgumentCount(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr string
+	}{
+		{"startsWith - too few arguments", "startsWith('a')", "error calling startsWith: invalid number of arguments. expected 2, got 1"},
+		{"startsWith - too many arguments", "startsWith('a', 'b', 'c')", "error calling startsWith: invalid number of arguments. expected 2, got 3"},
+		{"endsWith - too few arguments", "endsWith()", "error calling endsWith: invalid number of arguments. expected 2, got 0"},
+		{"fromJSON - too many arguments", "fromJSON('1', '2')", "error calling fromJSON: invalid number of arguments. expected 1, got 2"},
+		{"success - too many arguments", "success(1)", "error calling success: invalid number of arguments. expected 0, got 1"},
+		{"format - too few arguments", "format()", "error calling format: invalid number of arguments. expected at least 1, got 0"},
+		{"join - too few arguments", "join()", "error calling join: invalid number of arguments. expected at least 1, got 0"},
+		{"join - too many arguments", "join(fromJSON('[\"a\",\"b\"]'), ',', ',')", "error calling join: invalid number of arguments. expected at most 2, got 3"},
+		{"hashFiles - too few arguments", "hashFiles()", "error calling hashFiles: invalid number of arguments. expected at least 1, got 0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Evaluate(tt.input, nil)
+			if err == nil {
+				t.Fatalf("Evaluate() did not fail for %v", tt.input)
+			}
+
+			if err.Error() != tt.wantErr {
+				t.Errorf("Evaluate() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_Parse_ArithmeticNotSupported(t *testing.T) {
+	// Arithmetic is not part of the expression language, make sure it never reaches the evaluator
+	inputs := []string{"1 + 1", "'5' - 2", "2 * 3", "1 / 0", "5 % 2", "fromJSON('[1]') + fromJSON('[2]')", "fromJSON('{}') * 2", "[1, 2]", "...github.event"}
+	for _, input := range inputs {
+		t.Run(input, func(t *testing.T) {
+			lexer := actionlint.NewExprLexer(input + "}}")
+			parser := actionlint.NewExprParser()
+			if _, perr := parser.Parse(lexer); perr == nil {
+				t.Errorf("Parse() did not fail for %v", input)
+			}
+		})
+	}
+}
+
+func TestEvaluate_NaN(t *testing.T) {
+	ctx := MapContext{
+		"env": ContextData{
+			"NAN": math.NaN(),
+			"STR": "abc",
+		},
+	}
+
+	// NaN is not equal to anything, including itself, and all orderings are false
+	inputs := []string{
+		"env.NAN == env.NAN",
+		"env.NAN == 0",
+		"env.NAN >= env.NAN",
+		"env.NAN < 1",
+		"env.STR == 0",
+		"env.STR > 0",
+		"env.STR <= 0",
+		"fromJSON('{}') >= 0",
+		"fromJSON('{}') < 0",
+	}
+	for _, input := range inputs {
+		t.Run(input, func(t *testing.T) {
+			got, err := Evaluate(input, ctx)
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+
+			if got.Value != false {
+				t.Errorf("Evaluate() = %v, want false", got.Value)
+			}
+		})
+	}
+
+	got, err := Evaluate("env.NAN != env.NAN", ctx)
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+
+	if got.Value != true {
+		t.Errorf("Evaluate() = %v, want true", got.Value)
+	}
+}
+
+func Test_Parse_NumberLiterals(t *testing.T) {
+	// Number literals are parsed by actionlint, which has no leading-dot, octal, or binary forms
+	inputs := []string{".5", "-.5", "1.", "0o17", "0b1", "017", "+1"}
+	for _, input := range inputs {
+		t.Run(input, func(t *testing.T) {
+			if _, err := Evaluate(input, nil); err == nil {
+				t.Errorf("Evaluate() did not fail for %v", input)
+			}
+		})
+	}
+}
+
+func TestEvaluate_ArrayConcatenationNotSupported(t *testing.T) {
+	_, err := Evaluate("fromJSON('[1]') + fromJSON('[2]')", nil)
+	if err == nil || !strings.HasPrefix(err.Error(), "could not parse expression") {
+		t.Errorf("Evaluate() error = %v, want parse error", err)
+	}
+
+	// Arrays used as numbers are NaN, which is not equal to anything and makes every ordering false
+	for _, input := range []string{"fromJSON('[1]') == 1", "fromJSON('[1]') > 0", "fromJSON('[1]') <= 1", "fromJSON('[]') == 0"} {
+		got, err := Evaluate(input, nil)
+		if err != nil {
+			t.Fatalf("Evaluate() error = %v", err)
+		}
+
+		if got.Value != false {
+			t.Errorf("Evaluate(%v) = %v, want false", input, got.Value)
+		}
+	}
+
+	if _, err := (&Evaluator{Strict: true}).Evaluate("fromJSON('[1]') > 0", nil); err == nil {
+		t.Errorf("Evaluate() in strict mode did not fail")
+	}
+}
+
+func TestEvaluate_Expression(t *testing.T) {
+	got, err := Evaluate("input.foo == 'bar'", MapContext{"input": ContextData{"foo": "bar"}})
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+
+	want := &EvaluationResult{Value: true, Type: &actionlint.BoolType{}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Evaluate() = %v, want %v", got, want)
+	}
+}
+
+func TestEvaluate_SyntaxError(t *testing.T) {
+	inputs := []string{"input.foo ==", "startsWith('a'", "'unterminated", ""}
+	for _, input := range inputs {
+		t.Run(input, func(t *testing.T) {
+			got, err := Evaluate(input, nil)
+			if err == nil {
+				t.Fatalf("Evaluate() did not fail, got %v", got)
+			}
+		})
+	}
+}
+
+func TestEvaluate_BooleanStringEquality(t *testing.T) {
+	// Booleans compared with strings are converted to numbers on both sides, so strings spelling a
+	// boolean are never equal to one
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{"true == 'True'", false},
+		{"true == 'true'", false},
+		{"true == 'TRUE'", false},
+		{"'True' == true", false},
+		{"false == 'false'", false},
+		{"false == 'False'", false},
+		{"true == '1'", true},
+		{"'1' == true", true},
+		{"true == '1e0'", true},
+		{"true == '01'", true},
+		{"false == '0'", true},
+		{"false == '0.0'", true},
+		{"false == ''", true},
+		{"false == '  '", true},
+		{"'' == false", true},
+		{"true == ''", false},
+		{"true != 'True'", true},
+		{"false != ''", false},
+		{"!('true' == true)", true},
+		{"fromJSON('true') == 'true'", false},
+		{"github.flag == 'true'", false},
+		{"github.flag == true", true},
+		{"github.text == true", false},
+	}
+	ctx := MapContext{"github": ContextData{"flag": true, "text": "true"}}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := Evaluate(tt.input, ctx)
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+
+			if got.Value != tt.want {
+				t.Errorf("Evaluate() = %v, want %v", got.Value, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluate_NumberStringEquality(t *testing.T) {
+	// Strings compared with numbers are converted to numbers, so any spelling of the same number is
+	// equal to it
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{"fromJSON('1') == '1'", true},
+		{"'1' == fromJSON('1')", true},
+		{"1 == '1'", true},
+		{"1.0 == '1'", true},
+		{"1 == '1.0'", true},
+		{"1.0 == '1.0'", true},
+		{"1.5 == '1.5'", true},
+		{"1.5 == '1.50'", true},
+		{"'1.50' == 1.5", true},
+		{"fromJSON('1.0') == '1.00'", true},
+		{"fromJSON('[1.0]')[0] == '1'", true},
+		{"1 == ' 1 '", true},
+		{"1 == '1e0'", true},
+		{"0 == '-0'", true},
+		{"1 == '1.5'", false},
+		{"1.5 == '1'", false},
+		{"1 == '1a'", false},
+		{"1 != '1.0'", false},
+		{"github.count == '3'", true},
+		{"github.count == '3.0'", true},
+		{"github.count == '3.5'", false},
+		{"github.ratio == '0.25'", true},
+	}
+	ctx := MapContext{"github": ContextData{"count": float64(3), "ratio": 0.25}}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := Evaluate(tt.input, ctx)
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+
+			if got.Value != tt.want {
+				t.Errorf("Evaluate() = %v, want %v", got.Value, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse_NotFunctionCall(t *testing.T) {
+	n, err := parse("!contains(github.ref, 'main')", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	not, ok := n.(*actionlint.NotOpNode)
+	if !ok {
+		t.Fatalf("parse() = %T, want *actionlint.NotOpNode", n)
+	}
+
+	if call, ok := not.Operand.(*actionlint.FuncCallNode); !ok || call.Callee != "contains" || len(call.Args) != 2 {
+		t.Errorf("operand of ! = %#v, want call of contains", not.Operand)
+	}
+}
+
+func TestEvaluate_IndexPrimitive(t *testing.T) {
+	// Strings are not indexed by character, indexes of primitives are null like their properties
+	ctx := MapContext{"github": ContextData{"sha": "abc", "run_number": float64(3)}}
+
+	inputs := []string{
+		"'abc'[0]",
+		"'abc'['0']",
+		"'abc'['length']",
+		"github.sha[0]",
+		"github.sha[1][0]",
+		"github['sha'][0]",
+		"github.run_number[0]",
+		"true[0]",
+		"null[0]",
+		"fromJSON('\"abc\"')[0]",
+	}
+	for _, input := range inputs {
+		t.Run(input, func(t *testing.T) {
+			got, err := Evaluate(input, ctx)
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+
+			if got.Value != nil {
+				t.Errorf("Evaluate() = %v, want null", got)
+			}
+		})
+	}
+}
+
+func TestEvaluate_IndexObjectNonString(t *testing.T) {
+	// Indexes of objects are not converted to strings, only string indexes are property names
+	ctx := MapContext{"github": ContextData{"1": "one", "true": "yes", "null": "none"}}
+
+	inputs := []string{
+		"fromJSON('{\"a\":1}')[1]",
+		"fromJSON('{\"1\":1}')[1]",
+		"github[1]",
+		"github[true]",
+		"github[null]",
+		"github[github]",
+		"github[fromJSON('[]')]",
+	}
+	for _, input := range inputs {
+		t.Run(input, func(t *testing.T) {
+			got, err := Evaluate(input, ctx)
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+
+			if _, ok := got.Type.(*actionlint.NullType); !ok || got.Value != nil {
+				t.Errorf("Evaluate() = %v, want null", got)
+			}
+		})
+	}
+}
+
+func TestEvaluate_NumericStringOrdering(t *testing.T) {
+	// Both sides of <, <=, >, and >= are converted to numbers, so numeric strings are never ordered
+	// lexically, where '10' would be less than '9'
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{"'10' > 9", true},
+		{"9 < '10'", true},
+		{"'10' > '9'", true},
+		{"'10' < '9'", false},
+		{"'10' >= '9'", true},
+		{"'9' <= '10'", true},
+		{"'2' > '10'", false},
+		{"'1.5' < '1.25'", false},
+		{"' 10 ' > '9'", true},
+		{"'0X10' > '9'", true},
+		{"'0b11' == 3", true},
+		{"'0x10' > '9'", true},
+		{"'1e1' >= '10'", true},
+		{"github.run_number > '9'", true},
+		{"github.version < '9'", false},
+
+		// Strings that are not numbers are NaN, which is neither less nor greater than anything
+		{"'b' > 'a'", false},
+		{"'a' < 'b'", false},
+		{"'10a' > '9'", false},
+		{"'infinity' > 1000", false},
+		{"'-inf' < 0", false},
+		{"'1_0' > 9", false},
+		{"'0x1p3' >= 8", false},
+	}
+	ctx := MapContext{"github": ContextData{"run_number": float64(10), "version": "10"}}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := Evaluate(tt.input, ctx)
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+
+			if got.Value != tt.want {
+				t.Errorf("Evaluate() = %v, want %v", got.Value, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluate_Whitespace(t *testing.T) {
+	ctx := MapContext{"github": ContextData{"sha": "abc"}}
+
+	inputs := []string{"  github.sha  ", "\tgithub.sha\n", "\n  github.sha\r\n", "github . sha", "( github.sha )", " github.sha == 'abc' && github.sha "}
+	for _, input := range inputs {
+		t.Run(input, func(t *testing.T) {
+			got, err := Evaluate(input, ctx)
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+
+			if got.CoerceString() != "abc" {
+				t.Errorf("Evaluate() = %v, want %v", got.Value, "abc")
+			}
+		})
+	}
+}
+
+func TestEvaluate_StrayCharacters(t *testing.T) {
+	tests := []struct {
+		input   string
+		wantErr string
+	}{
+		{"github.sha }}", "could not parse expression: unexpected '}}' at column 12"},
+		{"github.sha }} || true", "could not parse expression: unexpected '}}' at column 12"},
+		{"github.sha foo", "parser did not reach end of input"},
+		{"github.sha # comment", "unexpected character '#'"},
+		{"github.sha;", "unexpected character ';'"},
+		{"   ", "unexpected end of input"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := Evaluate(tt.input, MapContext{"github": ContextData{"sha": "abc"}})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Evaluate() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// Closing braces in string literals are allowed
+	got, err := Evaluate("'}}' == '}}'", nil)
+	if err != nil || got.Value != true {
+		t.Errorf("Evaluate() = %v, %v, want true", got, err)
+	}
+}
+
+func TestEvaluate_Precedence(t *testing.T) {
+	ctx := MapContext{"env": ContextData{"a": "a", "b": "b"}}
+
+	tests := []struct {
+		input string
+		want  interface{}
+	}{
+		// && binds stronger than ||
+		{"true || false && false", true},
+		{"false && true || true", true},
+		{"false && false || true && true", true},
+		{"(true || false) && false", false},
+
+		// Comparisons bind stronger than && and ||
+		{"true || false == false", true},
+		{"'a' == 'A' && 1 < 2", true},
+		{"false || 1 == 1", true},
+
+		// ! binds stronger than comparisons and logical operators
+		{"!'' == 'x'", false},
+		{"!env.a == env.b", false},
+		{"!(env.a == env.b)", true},
+		{"!false && false", false},
+		{"!(false && false)", true},
+
+		// Property and index access bind stronger than !
+		{"!fromJSON('[0]')[0]", true},
+		{"fromJSON('[1]')[0] == 1", true},
+
+		// <, <=, >, >= bind stronger than == and !=
+		{"1 < 2 == true", true},
+		{"0 == 1 > 2", true},
+		{"2 == 3 > 1", false},
+		{"(0 == 1) > 2", false},
+
+		// Comparisons of the same precedence are evaluated from left to right
+		{"3 > 2 > 1", false},
+		{"1 == 2 == false", true},
+		{"3 > (2 > 1)", true},
+		{"1 == (2 == false)", false},
+		{"(1 < 2) == true", true},
+		{"((1 == 2)) == (false)", true},
+		{"1 < (2) == true", true},
+		{"1 < (2 == 2) == true", false},
+		{"(1 < 2) == (2 < 3)", true},
+		{"3 > 2 > 1 && true", false},
+		{"format('{0}', 3 > 2 > 1)", "false"},
+		{"format('{0}{1}', 3 > (2 > 1), 3 > 2 > 1)", "truefalse"},
+		{"format('{0}', (1 == 2) == false) == format('{0}', 1 == (2 == false))", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := Evaluate(tt.input, ctx)
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+
+			if got.Value != tt.want {
+				t.Errorf("Evaluate() = %v, want %v", got.Value, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluate_Grouping(t *testing.T) {
+	nested := func(depth int, expr string) string {
+		return strings.Repeat("(", depth) + expr + strings.Repeat(")", depth)
+	}
+
+	tests := []struct {
+		input string
+		want  interface{}
+	}{
+		{"(true || false) && false", false},
+		{"true || (false && false)", true},
+		{"(false && false) || true", true},
+		{"false && (false || true)", false},
+		{"!(true && false)", true},
+		{"!(false || false) && false", false},
+		{"((true || false)) && ((false))", false},
+		{"(true || (false && (false || (true && false))))", true},
+		{"((((false || true) && true) || false) && (true && (false || true)))", true},
+		{"(1 == 1) && (2 == 3)", false},
+		{"(1 == 1) == (2 == 3)", false},
+		{"(1 < 2) && ('a' == 'A')", true},
+		{"('' || 'x') && ('y' || '')", "y"},
+		{"(null || 0) || (false && 'x')", false},
+		{nested(100, "true || false") + " && false", false},
+		{"true || " + nested(100, "false && false"), true},
+		{nested(50, "1 < 2") + " == " + nested(50, "3 > 2"), true},
+		{nested(20, "!"+nested(20, "false")), true},
+	}
+	for _, tt := range tests {
+		name := tt.input
+		if len(name) > 60 {
+			name = name[:60]
+		}
+
+		t.Run(name, func(t *testing.T) {
+			got, err := Evaluate(tt.input, nil)
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+
+			if got.Value != tt.want {
+				t.Errorf("Evaluate() = %v, want %v", got.Value, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluate_Panic(t *testing.T) {
+	t.Cleanup(func() {
+		delete(functions, "panics")
+	})
+
+	if err := RegisterFunction("panics", 0, func(args ...*EvaluationResult) (*EvaluationResult, error) {
+		panic("boom")
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input   string
+		wantCol int
+	}{
+		{"panics()", 1},
+		{"true && PANICS()", 9},
+		{"format('{0}', panics())", 15},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := Evaluate(tt.input, nil)
+
+			var evalErr *EvaluationError
+			if !errors.As(err, &evalErr) {
+				t.Fatalf("Evaluate() error = %v, want EvaluationError", err)
+			}
+
+			if want := "function panicked: boom"; evalErr.Err.Error() != want {
+				t.Errorf("EvaluationError.Err = %v, want %v", evalErr.Err, want)
+			}
+
+			if pos := evalErr.Pos(); pos == nil || pos.Col != tt.wantCol {
+				t.Errorf("EvaluationError.Pos() = %v, want column %d", pos, tt.wantCol)
+			}
+		})
+	}
+
+	// The panic is also recovered when evaluating parsed expressions
+	n, err := parse("panics()", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := (&Evaluator{}).EvaluateNode(n, nil); err == nil || err.Error() != "error calling panics: function panicked: boom" {
+		t.Errorf("EvaluateNode() error = %v", err)
+	}
+}
+
+func TestEvaluateAll(t *testing.T) {
+	ctx := &countingContext{MapContext: MapContext{
+		"github": ContextData{"event_name": "push", "sha": "abc"},
+		"env":    ContextData{"A": ""},
+	}}
+
+	exprs := []string{
+		"github.event_name == 'push'",
+		"github.sha }}",
+		"github.sha",
+		"fromJSON('{')",
+		"env.A || 'x'",
+		"fromJSON('[1]')",
+		"fromJSON('[1]')",
+		"undefined()",
+	}
+	results, errs := EvaluateAll(exprs, ctx)
+	if len(results) != len(exprs) || len(errs) != len(exprs) {
+		t.Fatalf("EvaluateAll() returned %d results and %d errors, want %d", len(results), len(errs), len(exprs))
+	}
+
+	want := []interface{}{true, nil, "abc", nil, "x", []interface{}{float64(1)}, []interface{}{float64(1)}, nil}
+	wantErr := []string{"", "unexpected '}}' at column 12", "", "could not parse JSON", "", "", "", "undefined function undefined"}
+	for i, expr := range exprs {
+		if wantErr[i] != "" {
+			if errs[i] == nil || !strings.Contains(errs[i].Error(), wantErr[i]) {
+				t.Errorf("EvaluateAll() error of %q = %v, want %v", expr, errs[i], wantErr[i])
+			}
+
+			if results[i] != nil {
+				t.Errorf("EvaluateAll() result of %q = %v, want nil", expr, results[i].Value)
+			}
+			continue
+		}
+
+		if errs[i] != nil {
+			t.Errorf("EvaluateAll() error of %q = %v", expr, errs[i])
+			continue
+		}
+
+		if !reflect.DeepEqual(results[i].Value, want[i]) {
+			t.Errorf("EvaluateAll() result of %q = %v, want %v", expr, results[i].Value, want[i])
+		}
+	}
+
+	// Every context is only looked up once
+	if wantLookups := []string{"github", "env"}; !reflect.DeepEqual(ctx.lookups, wantLookups) {
+		t.Errorf("Lookup() called with %v, want %v", ctx.lookups, wantLookups)
+	}
+
+	// Results of different expressions are distinct values
+	if results[5].Equals(results[6]) {
+		t.Errorf("results of separate fromJSON() calls are equal")
+	}
+}
+
+// unknownNode is a node type the evaluator does not know.
+type unknownNode struct{}
+
+func (unknownNode) Token() *actionlint.Token { return nil }
+
+func TestEvaluateNode_Panic(t *testing.T) {
+	inconsistent := WithFunctions(map[string]FunctionDefinition{"inconsistent": {0, func(args ...*EvaluationResult) (*EvaluationResult, error) {
+		// A boolean type with a string value
+		return &EvaluationResult{"x", &actionlint.BoolType{}}, nil
+	}}})
+
+	n, err := parse("!inconsistent()", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	evaluators := []interface {
+		EvaluateNode(n actionlint.ExprNode, context Context) (*EvaluationResult, error)
+	}{NewEvaluator(inconsistent), NewCachingEvaluator(10, inconsistent)}
+	for _, ev := range evaluators {
+		for _, node := range []actionlint.ExprNode{n, unknownNode{}, &actionlint.LogicalOpNode{Kind: actionlint.LogicalOpNodeKindAnd, Left: &actionlint.BoolNode{Value: true}, Right: unknownNode{}}} {
+			if _, err := ev.EvaluateNode(node, nil); err == nil || !strings.HasPrefix(err.Error(), "could not evaluate expression: ") {
+				t.Errorf("%T.EvaluateNode(%T) error = %v, want recovered panic", ev, node, err)
+			}
+		}
+	}
+
+	if _, err := EvaluateNode(unknownNode{}, nil); err == nil || err.Error() != "could not evaluate expression: unknown node" {
+		t.Errorf("EvaluateNode() error = %v, want recovered panic", err)
+	}
+}
+
+func Test_Evaluate_ErrorPosition(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  actionlint.Pos
+	}{
+		{"format - missing argument", "format('{0}')", actionlint.Pos{Line: 1, Col: 1}},
+		{"format - nested", "true && format('{0}')", actionlint.Pos{Line: 1, Col: 9}},
+		{"fromJSON - invalid JSON", "github.ref == 'x' || fromJSON('{')", actionlint.Pos{Line: 1, Col: 22}},
+		{"startsWith - invalid number of arguments", "!startsWith('a')", actionlint.Pos{Line: 1, Col: 2}},
+		{"undefined function", "format('{0}', fromJSN('1'))", actionlint.Pos{Line: 1, Col: 15}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Evaluate(tt.input, nil)
+
+			var perr interface{ Pos() *actionlint.Pos }
+			if !errors.As(err, &perr) {
+				t.Fatalf("Evaluate() error = %v, want error with position", err)
+			}
+
+			if got := perr.Pos(); got == nil || *got != tt.want {
+				t.Errorf("Pos() = %v, want %v", got, &tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluator_Strict(t *testing.T) {
+	ctx := MapContext{
+		"github": ContextData{
+			"event_name": "push",
+			"run_number": float64(3),
+		},
+	}
+
+	tests := []struct {
+		input      string
+		lenient    interface{}
+		wantStrict string
+	}{
+		{"'abc' > 1", false, "strict mode: cannot compare string with number"},
+		{"1 == '1'", true, "strict mode: cannot compare number with string"},
+		{"true == 1", true, "strict mode: cannot compare boolean with number"},
+		{"github == 'push'", false, "strict mode: cannot compare object with string"},
+		{"'a' < 'b'", false, "strict mode: cannot order values of type string"},
+		{"null < 1", true, "strict mode: cannot compare null with number"},
+		{"format(1)", "1", "error calling format: strict mode: format string must be a string, got number"},
+
+		// Allowed in strict mode
+		{"github.event_name == 'PUSH'", true, ""},
+		{"github.run_number >= 3", true, ""},
+		{"github.unknown == null", true, ""},
+		{"null != github.event_name", true, ""},
+		{"!github.unknown && github.event_name", "push", ""},
+		{"format('{0}', 1)", "1", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := (&Evaluator{}).Evaluate(tt.input, ctx)
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+
+			if got.Value != tt.lenient {
+				t.Errorf("Evaluate() = %v, want %v", got.Value, tt.lenient)
+			}
+
+			got, err = (&Evaluator{Strict: true}).Evaluate(tt.input, ctx)
+			if tt.wantStrict == "" {
+				if err != nil {
+					t.Fatalf("Evaluate() in strict mode error = %v", err)
+				}
+
+				if got.Value != tt.lenient {
+					t.Errorf("Evaluate() in strict mode = %v, want %v", got.Value, tt.lenient)
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatalf("Evaluate() in strict mode = %v, want error", got.Value)
+			}
+
+			if err.Error() != tt.wantStrict {
+				t.Errorf("Evaluate() in strict mode error = %v, want %v", err, tt.wantStrict)
+			}
+		})
+	}
+}
+
+func TestEvaluateNode_ComparisonChains(t *testing.T) {
+	// Trees from the actionlint parser are not regrouped, chains of comparisons are grouped to the right
+	n, perr := actionlint.NewExprParser().Parse(actionlint.NewExprLexer("3 > 2 > 1}}"))
+	if perr != nil {
+		t.Fatal(perr)
+	}
+
+	got, err := EvaluateNode(n, nil)
+	if err != nil {
+		t.Fatalf("EvaluateNode() error = %v", err)
+	}
+
+	if got.Value != true {
+		t.Errorf("EvaluateNode() = %v, want true", got.Value)
+	}
+
+	got, err = Evaluate("3 > 2 > 1", nil)
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+
+	if got.Value != false {
+		t.Errorf("Evaluate() = %v, want false", got.Value)
+	}
+}
+
+func TestEvaluate_DeeplyNestedComparisons(t *testing.T) {
+	// Each comparison is parenthesized, so it is its own chain
+	expr := strings.Repeat("true == (", 20000) + "true" + strings.Repeat(")", 20000)
+	got, err := Evaluate(expr, nil)
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+
+	if got.Value != true {
+		t.Errorf("Evaluate() = %v, want true", got.Value)
+	}
+}
+
+func TestEvaluator_MaxDepth(t *testing.T) {
+	// Parentheses do not create nodes, each comparison adds one level
+	nested := func(depth int) string {
+		return strings.Repeat("(", depth) + "true" + strings.Repeat(" == true)", depth)
+	}
+
+	tests := []struct {
+		input    string
+		maxDepth int
+		wantErr  bool
+	}{
+		{nested(100), 0, false},
+		{nested(9), 10, false},
+		{nested(10), 10, true},
+		{nested(1000), 10, true},
+		{strings.Repeat("!", 20) + "true", 10, true},
+		{"github.event.pull_request.head.repo.name", 10, false},
+		{"github.event.pull_request.head.repo.name", 5, true},
+		{"format('{0}', fromJSON('[[[[[[[[1]]]]]]]]'))", 3, false},
+
+		// Deep expressions fail before comparisons are regrouped
+		{strings.Repeat("true == (", 20000) + "true" + strings.Repeat(")", 20000), 10, true},
+		{"false && " + nested(10), 10, true},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d %.40s", tt.maxDepth, tt.input), func(t *testing.T) {
+			_, err := (&Evaluator{MaxDepth: tt.maxDepth}).Evaluate(tt.input, nil)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Evaluate() did not fail")
+				}
+
+				if want := fmt.Sprintf("expression exceeds the maximum depth of %d", tt.maxDepth); !strings.HasSuffix(err.Error(), want) {
+					t.Errorf("Evaluate() error = %v, want %v", err, want)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("Evaluate() error = %v", err)
+			}
+		})
+	}
+}
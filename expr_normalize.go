@@ -0,0 +1,180 @@
+package expr
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/rhysd/actionlint"
+)
+
+// Normalize parses the given expression, without the surrounding `${{ }}`, and returns it in a
+// canonical form: whitespace is collapsed, function names are spelled like they are registered,
+// properties and contexts are lower case, numbers are printed in decimal, and only required
+// parentheses are kept. The normalized expression evaluates exactly like the original one.
+func Normalize(expr string) (string, error) {
+	n, err := parse(expr, 0)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	writeNode(&sb, n)
+
+	return sb.String(), nil
+}
+
+// Precedence of nodes, higher binds stronger
+const (
+	precOr = iota + 1
+	precAnd
+	precEquality
+	precRelational
+	precNot
+	precPostfix
+)
+
+func precedence(n actionlint.ExprNode) int {
+	switch tn := n.(type) {
+	case *actionlint.LogicalOpNode:
+		if tn.Kind == actionlint.LogicalOpNodeKindOr {
+			return precOr
+		}
+
+		return precAnd
+
+	case *actionlint.CompareOpNode:
+		if isRelational(tn.Kind) {
+			return precRelational
+		}
+
+		return precEquality
+
+	case *actionlint.NotOpNode:
+		return precNot
+	}
+
+	return precPostfix
+}
+
+// writeOperand writes n, wrapped in parentheses if it binds weaker than prec.
+func writeOperand(sb *strings.Builder, n actionlint.ExprNode, prec int) {
+	if precedence(n) < prec {
+		sb.WriteByte('(')
+		writeNode(sb, n)
+		sb.WriteByte(')')
+		return
+	}
+
+	writeNode(sb, n)
+}
+
+var compareOperators = map[actionlint.CompareOpNodeKind]string{
+	actionlint.CompareOpNodeKindLess:      "<",
+	actionlint.CompareOpNodeKindLessEq:    "<=",
+	actionlint.CompareOpNodeKindGreater:   ">",
+	actionlint.CompareOpNodeKindGreaterEq: ">=",
+	actionlint.CompareOpNodeKindEq:        "==",
+	actionlint.CompareOpNodeKindNotEq:     "!=",
+}
+
+func writeNode(sb *strings.Builder, n actionlint.ExprNode) {
+	switch tn := n.(type) {
+	case *actionlint.NullNode:
+		sb.WriteString("null")
+
+	case *actionlint.BoolNode:
+		sb.WriteString(strconv.FormatBool(tn.Value))
+
+	case *actionlint.IntNode:
+		sb.WriteString(strconv.Itoa(tn.Value))
+
+	case *actionlint.FloatNode:
+		sb.WriteString(formatFloatLiteral(tn.Value))
+
+	case *actionlint.StringNode:
+		sb.WriteByte('\'')
+		sb.WriteString(strings.ReplaceAll(tn.Value, "'", "''"))
+		sb.WriteByte('\'')
+
+	case *actionlint.VariableNode:
+		sb.WriteString(tn.Name)
+
+	case *actionlint.ObjectDerefNode:
+		writeOperand(sb, tn.Receiver, precPostfix)
+		sb.WriteByte('.')
+		sb.WriteString(tn.Property)
+
+	case *actionlint.ArrayDerefNode:
+		writeOperand(sb, tn.Receiver, precPostfix)
+		sb.WriteString(".*")
+
+	case *actionlint.IndexAccessNode:
+		writeOperand(sb, tn.Operand, precPostfix)
+		sb.WriteByte('[')
+		writeNode(sb, tn.Index)
+		sb.WriteByte(']')
+
+	case *actionlint.FuncCallNode:
+		sb.WriteString(canonicalFunctionName(tn.Callee))
+		sb.WriteByte('(')
+		for i, arg := range tn.Args {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+
+			writeNode(sb, arg)
+		}
+		sb.WriteByte(')')
+
+	case *actionlint.NotOpNode:
+		sb.WriteByte('!')
+		writeOperand(sb, tn.Operand, precNot)
+
+	case *actionlint.CompareOpNode:
+		// Comparisons are left-associative
+		prec := precedence(tn)
+		writeOperand(sb, tn.Left, prec)
+		sb.WriteString(" " + compareOperators[tn.Kind] + " ")
+		writeOperand(sb, tn.Right, prec+1)
+
+	case *actionlint.LogicalOpNode:
+		prec := precedence(tn)
+		writeOperand(sb, tn.Left, prec+1)
+		sb.WriteString(" " + tn.Kind.String() + " ")
+		writeOperand(sb, tn.Right, prec)
+	}
+}
+
+// canonicalFunctionName returns the registered spelling of the given function name, or the name as is
+// for unknown functions.
+func canonicalFunctionName(name string) string {
+	key := strings.ToLower(name)
+	if f, ok := lookupFunction(key); ok {
+		return f.name
+	}
+
+	if f, ok := extendedFunctions[key]; ok {
+		return f.name
+	}
+
+	return name
+}
+
+// formatFloatLiteral formats a number so that actionlint parses it back to the same value. Exponents
+// are written without a plus sign and leading zeros, which the lexer does not accept.
+func formatFloatLiteral(f float64) string {
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+
+	mantissa, exp, ok := strings.Cut(s, "e")
+	if !ok {
+		// Integers would be parsed as int literals, which evaluate to the same number
+		return s
+	}
+
+	sign := ""
+	if exp[0] == '-' {
+		sign = "-"
+	}
+
+	return mantissa + "e" + sign + strings.TrimLeft(exp[1:], "0")
+}
@@ -0,0 +1,116 @@
+package expr
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"github.sha", "github.sha"},
+		{"  GitHub.Event .  Issue.TITLE ", "github.event.issue.title"},
+		{"FromJSON( env.X )", "fromJSON(env.x)"},
+		{"fromjson(env.x).a", "fromJSON(env.x).a"},
+		{"STARTSWITH(github.ref,'refs/tags/')", "startsWith(github.ref, 'refs/tags/')"},
+		{"unknownFunc(1,2)", "unknownFunc(1, 2)"},
+		{"'it''s'", "'it''s'"},
+		{"0xFF==255", "255 == 255"},
+		{"1.50", "1.5"},
+		{"1e3", "1000"},
+		{"1.5e21", "1.5e21"},
+		{"2.5e-7", "2.5e-7"},
+		{"-0x10", "-16"},
+		{"((a))", "a"},
+		{"(a && b) || c", "a && b || c"},
+		{"a && (b || c)", "a && (b || c)"},
+		{"(a && b) && c", "(a && b) && c"},
+		{"a && (b && c)", "a && b && c"},
+		{"!(a == b)", "!(a == b)"},
+		{"!a==b", "!a == b"},
+		{"!!a", "!!a"},
+		{"(a == b) == c", "a == b == c"},
+		{"a == (b == c)", "a == (b == c)"},
+		{"a == (b < c)", "a == b < c"},
+		{"(a == b) < c", "(a == b) < c"},
+		{"(a < b) < c", "a < b < c"},
+		{"a < (b < c)", "a < (b < c)"},
+		{"(a || b).c", "(a || b).c"},
+		{"(!a)[0]", "(!a)[0]"},
+		{"a.*.b[ 'c' ]", "a.*.b['c']"},
+		{"a[b || c]", "a[b || c]"},
+		{"format('{0}',a<=b)", "format('{0}', a <= b)"},
+		{"null!=true", "null != true"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := Normalize(tt.input)
+			if err != nil {
+				t.Fatalf("Normalize() error = %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("Normalize() = %v, want %v", got, tt.want)
+			}
+
+			// Normalizing is idempotent
+			again, err := Normalize(got)
+			if err != nil {
+				t.Fatalf("Normalize() of normalized expression error = %v", err)
+			}
+
+			if again != got {
+				t.Errorf("Normalize() of normalized expression = %v, want %v", again, got)
+			}
+		})
+	}
+}
+
+func TestNormalize_Semantics(t *testing.T) {
+	ctx := MapContext{
+		"github": ContextData{"ref": "refs/heads/main", "event": ContextData{"number": float64(3)}},
+		"env":    ContextData{"JSON": `{"a": [1, 2]}`},
+	}
+
+	inputs := []string{
+		"github.REF == 'refs/heads/MAIN' && (github.event.number > 2 || false)",
+		"(false || '') && 'x' || 'y'",
+		"!(github.event.number == 3) || fromJson(env.json).a[1]",
+		"join(FromJSON(env.JSON).a, '-')",
+		"1 == 1 == true",
+		"(1 == 1) == true",
+		"1 < 2 == true",
+		"3 > 2 > 1",
+		"1.5e1 == 15",
+	}
+	for _, input := range inputs {
+		t.Run(input, func(t *testing.T) {
+			normalized, err := Normalize(input)
+			if err != nil {
+				t.Fatalf("Normalize() error = %v", err)
+			}
+
+			want, err := Evaluate(input, ctx)
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+
+			got, err := Evaluate(normalized, ctx)
+			if err != nil {
+				t.Fatalf("Evaluate() of %v error = %v", normalized, err)
+			}
+
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Evaluate() of %v = %v, want %v", normalized, got, want)
+			}
+		})
+	}
+}
+
+func TestNormalize_SyntaxError(t *testing.T) {
+	if _, err := Normalize("github."); err == nil {
+		t.Error("Normalize() did not fail")
+	}
+}
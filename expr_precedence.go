@@ -0,0 +1,185 @@
+package expr
+
+import (
+	"github.com/rhysd/actionlint"
+)
+
+// regroupComparisons rewrites the chains of comparisons in n, parsed from expr, to follow GitHub's
+// precedence. actionlint parses all comparison operators with the same precedence and
+// right-associative, so `1 < 2 == true` is parsed like `1 < (2 == true)`. GitHub evaluates `<`, `<=`,
+// `>`, and `>=` before `==` and `!=`, and operators of the same precedence from left to right, like
+// `(1 < 2) == true`.
+//
+// The parser does not keep parentheses in the tree, so the tokens of expr are used to tell
+// `a == b == c` from `a == (b == c)`.
+func regroupComparisons(n actionlint.ExprNode, expr string) actionlint.ExprNode {
+	r := &regrouper{expr: expr, root: n}
+	return r.node(n)
+}
+
+type regrouper struct {
+	expr string
+	root actionlint.ExprNode
+
+	// Only computed once a chain of comparisons is found: the tokens of expr, the index of the token at
+	// each offset, the offset of the parenthesis closing the one at each token index, and the offset of
+	// the last token of each node of root
+	tokens   []*actionlint.Token
+	offsets  map[int]int
+	closing  map[int]int
+	lastToks map[actionlint.ExprNode]int
+}
+
+func (r *regrouper) node(n actionlint.ExprNode) actionlint.ExprNode {
+	switch tn := n.(type) {
+	case *actionlint.ObjectDerefNode:
+		tn.Receiver = r.node(tn.Receiver)
+
+	case *actionlint.ArrayDerefNode:
+		tn.Receiver = r.node(tn.Receiver)
+
+	case *actionlint.IndexAccessNode:
+		tn.Operand = r.node(tn.Operand)
+		tn.Index = r.node(tn.Index)
+
+	case *actionlint.FuncCallNode:
+		for i, arg := range tn.Args {
+			tn.Args[i] = r.node(arg)
+		}
+
+	case *actionlint.NotOpNode:
+		tn.Operand = r.node(tn.Operand)
+
+	case *actionlint.LogicalOpNode:
+		tn.Left = r.node(tn.Left)
+		tn.Right = r.node(tn.Right)
+
+	case *actionlint.CompareOpNode:
+		// Collect the operands of `a op b op c ...`, which actionlint nests to the right
+		operands := []actionlint.ExprNode{}
+		ops := []actionlint.CompareOpNodeKind{}
+		for cur := tn; ; {
+			operands = append(operands, r.node(cur.Left))
+			ops = append(ops, cur.Kind)
+
+			next, ok := cur.Right.(*actionlint.CompareOpNode)
+			if !ok || r.parenthesized(next) {
+				operands = append(operands, r.node(cur.Right))
+				break
+			}
+
+			cur = next
+		}
+
+		return groupComparisons(operands, ops)
+	}
+
+	return n
+}
+
+// parenthesized reports whether n, the right operand of a comparison, is wrapped in parentheses.
+func (r *regrouper) parenthesized(n actionlint.ExprNode) bool {
+	if r.tokens == nil {
+		r.index()
+	}
+
+	first, ok := r.offsets[n.Token().Offset]
+	if !ok {
+		return false
+	}
+
+	// Find the outermost of the parentheses directly before the first token of n
+	open := first
+	for open > 0 && r.tokens[open-1].Kind == actionlint.TokenKindLeftParen {
+		open--
+	}
+
+	if open == first {
+		return false
+	}
+
+	// The parentheses wrap all of n if they are closed after its last token, otherwise they only wrap
+	// its left operand
+	closed, ok := r.closing[open]
+	last, known := r.lastToks[n]
+	return ok && known && closed > last
+}
+
+// index lexes expr and records where parentheses are closed and where the nodes of root end, so that
+// parenthesized does not have to scan the tokens or the tree for every comparison of a chain.
+func (r *regrouper) index() {
+	r.offsets = map[int]int{}
+	r.closing = map[int]int{}
+
+	opened := []int{}
+	lexer := actionlint.NewExprLexer(r.expr + "}}")
+	for {
+		t := lexer.Next()
+		if t.Kind == actionlint.TokenKindEnd || t.Kind == actionlint.TokenKindUnknown {
+			break
+		}
+
+		switch t.Kind {
+		case actionlint.TokenKindLeftParen:
+			opened = append(opened, len(r.tokens))
+
+		case actionlint.TokenKindRightParen:
+			if len(opened) > 0 {
+				r.closing[opened[len(opened)-1]] = t.Offset
+				opened = opened[:len(opened)-1]
+			}
+		}
+
+		r.offsets[t.Offset] = len(r.tokens)
+		r.tokens = append(r.tokens, t)
+	}
+
+	// Children are left before their parent, which ends with the last token of any of them
+	r.lastToks = map[actionlint.ExprNode]int{}
+	ends := []int{}
+	actionlint.VisitExprNode(r.root, func(n, _ actionlint.ExprNode, entering bool) {
+		if entering {
+			end := 0
+			if t := n.Token(); t != nil {
+				end = t.Offset
+			}
+			ends = append(ends, end)
+			return
+		}
+
+		end := ends[len(ends)-1]
+		ends = ends[:len(ends)-1]
+		r.lastToks[n] = end
+		if len(ends) > 0 && end > ends[len(ends)-1] {
+			ends[len(ends)-1] = end
+		}
+	})
+}
+
+// groupComparisons returns the tree of `operands[0] ops[0] operands[1] ops[1] ...` with GitHub's
+// precedence.
+func groupComparisons(operands []actionlint.ExprNode, ops []actionlint.CompareOpNodeKind) actionlint.ExprNode {
+	// Relational operators bind stronger than equality operators
+	terms := []actionlint.ExprNode{operands[0]}
+	equalityOps := []actionlint.CompareOpNodeKind{}
+	for i, op := range ops {
+		if isRelational(op) {
+			terms[len(terms)-1] = &actionlint.CompareOpNode{Kind: op, Left: terms[len(terms)-1], Right: operands[i+1]}
+			continue
+		}
+
+		equalityOps = append(equalityOps, op)
+		terms = append(terms, operands[i+1])
+	}
+
+	n := terms[0]
+	for i, op := range equalityOps {
+		n = &actionlint.CompareOpNode{Kind: op, Left: n, Right: terms[i+1]}
+	}
+
+	return n
+}
+
+func isRelational(op actionlint.CompareOpNodeKind) bool {
+	return op != actionlint.CompareOpNodeKindEq && op != actionlint.CompareOpNodeKindNotEq
+}
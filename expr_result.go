@@ -1,10 +1,14 @@
 package expr
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"math"
 	"reflect"
 	"strconv"
-	"strings"
+
+	errs "github.com/pkg/errors"
 
 	"github.com/rhysd/actionlint"
 )
@@ -19,6 +23,118 @@ const (
 	Expression_False = "false"
 )
 
+// NewNull returns a null result.
+func NewNull() *EvaluationResult {
+	return &EvaluationResult{nil, &actionlint.NullType{}}
+}
+
+// NewBool returns a boolean result.
+func NewBool(b bool) *EvaluationResult {
+	return &EvaluationResult{b, &actionlint.BoolType{}}
+}
+
+// NewNumber returns a number result.
+func NewNumber(f float64) *EvaluationResult {
+	return &EvaluationResult{f, &actionlint.NumberType{}}
+}
+
+// NewString returns a string result.
+func NewString(s string) *EvaluationResult {
+	return &EvaluationResult{s, &actionlint.StringType{}}
+}
+
+// NewArray returns an array result. Elements have to use the same types as context values, like
+// float64 for numbers.
+func NewArray(values []interface{}) *EvaluationResult {
+	return &EvaluationResult{values, getExprType(values)}
+}
+
+// NewObject returns an object result. Values have to use the same types as context values.
+func NewObject(obj ContextData) *EvaluationResult {
+	return &EvaluationResult{obj, getExprType(obj)}
+}
+
+// AsBool returns the value of a boolean result. ok is false for results of other types, use
+// CoerceBool to convert them.
+func (ev *EvaluationResult) AsBool() (b bool, ok bool) {
+	b, ok = ev.Value.(bool)
+	return
+}
+
+// AsNumber returns the value of a number result. ok is false for results of other types, use
+// CoerceNumber to convert them.
+func (ev *EvaluationResult) AsNumber() (f float64, ok bool) {
+	switch v := ev.Value.(type) {
+	case float64:
+		return v, true
+	case json.Number:
+		return convertToNumber(v), true
+	}
+
+	return 0, false
+}
+
+// AsString returns the value of a string result. ok is false for results of other types, use
+// CoerceString to convert them. Like CoerceString, masked secrets are returned as `***`.
+func (ev *EvaluationResult) AsString() (s string, ok bool) {
+	switch v := ev.Value.(type) {
+	case string:
+		return v, true
+	case secretString, maskedString:
+		return maskedValue(v)
+	}
+
+	return "", false
+}
+
+// Clone returns a copy of the result. Arrays and objects are copied deeply, so modifying the value of
+// the copy does not affect the original. The copy is not equal to the original according to Equals,
+// like two objects returned by separate fromJSON() calls.
+func (ev *EvaluationResult) Clone() *EvaluationResult {
+	return &EvaluationResult{copyValue(ev.Value), ev.Type}
+}
+
+// Decode stores the value of the result in the value pointed to by target. Values are stored directly
+// if they have the type of the target, like string, bool, float64, []interface{}, or
+// map[string]interface{}. Other targets, like structs, are decoded from the JSON representation of
+// the value as returned by toJSON(), so struct fields can be mapped with json tags. Masked secrets
+// are decoded as `***`.
+func (ev *EvaluationResult) Decode(target interface{}) error {
+	rv := reflect.ValueOf(target)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.New("target must be a non-nil pointer")
+	}
+
+	// Values with secrets go through JSON, which masks them
+	if ev.Value != nil && !containsSecret(ev.Value) {
+		if v := reflect.ValueOf(ev.Value); v.Type().AssignableTo(rv.Elem().Type()) {
+			rv.Elem().Set(v)
+			return nil
+		}
+	}
+
+	data, err := json.Marshal(ev.Value)
+	if err != nil {
+		return errs.Wrap(err, "could not serialize value to JSON")
+	}
+
+	if err := json.Unmarshal(data, target); err != nil {
+		return errs.Wrap(err, "could not decode value")
+	}
+
+	return nil
+}
+
+// MarshalJSON encodes the value of the result, so results can be serialized along with other data,
+// both as values and as pointers. Unlike toJSON(), the output is not indented. Masked secrets are
+// encoded as `***`, numbers that are not finite cannot be encoded.
+func (ev EvaluationResult) MarshalJSON() ([]byte, error) {
+	return json.Marshal(ev.Value)
+}
+
+// Primitive reports whether the result is null, a boolean, a number, or a string. Functions operating
+// on strings, like contains() or startsWith(), convert primitive arguments to strings, so null is
+// treated like an empty string. Arrays and objects are not primitive.
 func (ev *EvaluationResult) Primitive() bool {
 	switch ev.Type.(type) {
 	case
@@ -33,7 +149,14 @@ func (ev *EvaluationResult) Primitive() bool {
 	}
 }
 
+// CoerceString converts the result to a string like GitHub does: null is an empty string, booleans are
+// `true` or `false`, numbers have at most 15 significant digits, arrays are `Array`, and objects are
+// `Object`. Masked secrets are `***`.
 func (ev *EvaluationResult) CoerceString() string {
+	if masked, ok := maskedValue(ev.Value); ok {
+		return masked
+	}
+
 	switch tt := ev.Type.(type) {
 	case *actionlint.NullType:
 		return ""
@@ -46,22 +169,118 @@ func (ev *EvaluationResult) CoerceString() string {
 		}
 
 	case *actionlint.NumberType:
-		// Preserve compat with C# implementation
-		if d := ev.Value.(float64); d == -0 {
-			return strconv.FormatFloat(float64(0), 'G', 15, 64)
+		if n, ok := ev.Value.(json.Number); ok {
+			return n.String()
 		}
 
-		dv := ev.Value.(float64)
-		return strconv.FormatFloat(dv, 'G', 15, 64)
+		return formatNumber(ev.Value.(float64))
 
 	case *actionlint.StringType:
-		return ev.Value.(string)
+		return toString(ev.Value)
+
+	case *actionlint.ArrayType:
+		return "Array"
+
+	case *actionlint.ObjectType:
+		return "Object"
 
 	default:
 		return tt.String()
 	}
 }
 
+// CoerceNumber converts the result to a number following the rules of `Number()` in Javascript:
+// booleans become 1 or 0, null and the empty string become 0, numeric strings are parsed, and all other
+// strings as well as arrays and objects become NaN.
+func (ev *EvaluationResult) CoerceNumber() float64 {
+	return convertToNumber(ev.Value)
+}
+
+// coerceValueString converts a value to a string like CoerceString, without determining its type first
+// for primitive values.
+func coerceValueString(v interface{}) string {
+	switch vt := v.(type) {
+	case nil:
+		return ""
+
+	case bool:
+		if vt {
+			return Expression_True
+		}
+
+		return Expression_False
+
+	case float64:
+		return formatNumber(vt)
+
+	case json.Number:
+		return vt.String()
+
+	case string:
+		return vt
+
+	case secretString, maskedString:
+		masked, _ := maskedValue(vt)
+		return masked
+
+	case []interface{}:
+		return "Array"
+
+	case ContextData:
+		return "Object"
+	}
+
+	return (&EvaluationResult{v, getExprType(v)}).CoerceString()
+}
+
+// unmaskedString is like CoerceString, but returns the actual value of masked secrets. Strings computed
+// from it must be masked again, like with newStringResult.
+func (ev *EvaluationResult) unmaskedString() string {
+	if ev.isSecret() {
+		return toString(ev.Value)
+	}
+
+	return ev.CoerceString()
+}
+
+// String returns a representation of the result for debugging, made of its kind and value, like
+// `Null`, `Bool(true)`, `Number(1.5)`, `String("abc")`, `Array[3]`, or `Object[2]` with the number of
+// elements or properties. Use CoerceString to convert the result to a string like GitHub does.
+func (ev *EvaluationResult) String() string {
+	if ev == nil {
+		return "<nil>"
+	}
+
+	switch vt := ev.Value.(type) {
+	case nil:
+		return "Null"
+
+	case bool:
+		return "Bool(" + strconv.FormatBool(vt) + ")"
+
+	case float64:
+		return "Number(" + formatNumber(vt) + ")"
+
+	case json.Number:
+		return "Number(" + vt.String() + ")"
+
+	case string:
+		return "String(" + strconv.Quote(vt) + ")"
+
+	case secretString, maskedString:
+		masked, _ := maskedValue(vt)
+		return "String(" + strconv.Quote(masked) + ")"
+
+	case []interface{}:
+		return "Array[" + strconv.Itoa(len(vt)) + "]"
+
+	case ContextData:
+		return "Object[" + strconv.Itoa(len(vt)) + "]"
+	}
+
+	return fmt.Sprintf("%T(%v)", ev.Value, ev.Value)
+}
+
 func (ev *EvaluationResult) Falsy() bool {
 	switch ev.Type.(type) {
 	case *actionlint.NullType:
@@ -71,17 +290,30 @@ func (ev *EvaluationResult) Falsy() bool {
 		return !ev.Value.(bool)
 
 	case *actionlint.NumberType:
-		dv := ev.Value.(float64)
+		dv := convertToNumber(ev.Value)
 		return dv == float64(0) || math.IsNaN(dv)
 
 	case *actionlint.StringType:
-		str := ev.Value.(string)
+		str := toString(ev.Value)
 		return str == ""
 	default:
 		return false
 	}
 }
+
+// IsTruthy reports whether the result is true when used as a condition, like in an `if:` key. It is
+// the same as CoerceBool.
+func (ev *EvaluationResult) IsTruthy() bool {
+	return ev.CoerceBool()
+}
+
 func (ev *EvaluationResult) Truthy() bool {
+	return ev.CoerceBool()
+}
+
+// CoerceBool converts the result to a boolean. null, false, 0, NaN, and the empty string are false,
+// everything else including arrays and objects is true.
+func (ev *EvaluationResult) CoerceBool() bool {
 	return !ev.Falsy()
 }
 
@@ -138,6 +370,29 @@ func coerceTypes(li interface{}, ri interface{}) (lv interface{}, ltype actionli
 	return
 }
 
+// kindName returns the name of the kind of value described by the given type, as used in messages.
+func kindName(t actionlint.ExprType) string {
+	switch t.(type) {
+	case *actionlint.NullType:
+		return "null"
+	case *actionlint.BoolType:
+		return "boolean"
+	case *actionlint.NumberType:
+		return "number"
+	case *actionlint.StringType:
+		return "string"
+	case *actionlint.ArrayType:
+		return "array"
+	case *actionlint.ObjectType:
+		return "object"
+	}
+
+	return "any"
+}
+
+// getExprType returns the type of the given value. Only the kind of arrays and objects is
+// determined, their elements are not looked at, so typing a value is cheap no matter how large it is.
+// Numbers are always float64, as there is no distinction between integers and floats in expressions.
 func getExprType(value interface{}) actionlint.ExprType {
 	if value == nil {
 		return &actionlint.NullType{}
@@ -146,42 +401,89 @@ func getExprType(value interface{}) actionlint.ExprType {
 	switch value.(type) {
 	case bool:
 		return &actionlint.BoolType{}
-	case float64:
+	case float64, json.Number:
 		return &actionlint.NumberType{}
-	case string:
+	case string, secretString, maskedString:
 		return &actionlint.StringType{}
 	}
 
 	t := reflect.TypeOf(value)
 	if t.Kind() == reflect.Array || t.Kind() == reflect.Slice {
-		// et := t.Elem()
-
 		return &actionlint.ArrayType{
-			Elem: &actionlint.AnyType{}, // TODO: getExprTypeForType(et),
+			Elem: &actionlint.AnyType{},
 		}
 	}
 
 	return &actionlint.ObjectType{
-		Props:  map[string]actionlint.ExprType{}, // TODO: Set types?
-		Mapped: &actionlint.AnyType{},            // TODO: Can we make this strict?
+		Props:  map[string]actionlint.ExprType{},
+		Mapped: &actionlint.AnyType{}, // TODO: Can we make this strict?
 	}
 }
 
-// func getExprTypeForType(t reflect.Type) actionlint.ExprType {
-// 	switch t.Kind() {
-// 	case reflect.Bool:
-// 		return &actionlint.BoolType{}
+// getDeepExprType returns the type of the given value like getExprType, but also types the elements
+// of arrays and the properties of objects. Arrays are typed by their elements if all elements have the
+// same type. It walks the whole value, so it is only used for the results of fromJSON().
+func getDeepExprType(value interface{}) actionlint.ExprType {
+	switch vt := value.(type) {
+	case []interface{}:
+		return &actionlint.ArrayType{
+			Elem: getElemType(vt),
+		}
+
+	case ContextData:
+		props := make(map[string]actionlint.ExprType, len(vt))
+		for k, v := range vt {
+			props[k] = getDeepExprType(v)
+		}
+
+		return &actionlint.ObjectType{
+			Props:  props,
+			Mapped: &actionlint.AnyType{},
+		}
+	}
 
-// 	case reflect.String:
-// 		return &actionlint.StringType{}
+	return getExprType(value)
+}
 
-// 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-// 		return &actionlint.NumberType{}
+// getElemType returns the common type of all elements, or any if they differ.
+func getElemType(values []interface{}) actionlint.ExprType {
+	if len(values) == 0 {
+		return &actionlint.AnyType{}
+	}
+
+	elem := getDeepExprType(values[0])
+	for _, v := range values[1:] {
+		if getDeepExprType(v).String() != elem.String() {
+			return &actionlint.AnyType{}
+		}
+	}
 
-// 	}
-// }
+	return elem
+}
 
+// Equals compares two results using the loose equality rules of expressions. The rules are applied
+// in order:
+//
+//  1. Values of the same type are compared directly. Strings are compared case-insensitively, NaN is
+//     not equal to anything, and arrays and objects are only equal to themselves.
+//  2. null and booleans are converted to numbers (null and false become 0, true becomes 1) when
+//     compared with a value of another type, and the comparison starts over.
+//  3. When a number and a string are compared, the string is converted to a number. Strings that are
+//     not numbers become NaN, so they are not equal to any number.
+//  4. Arrays and objects are never equal to a value of another type.
+//
+// A boolean compared with a string therefore ends up as a number compared with a string: `true == 1`
+// and `true == '1'` are true, but `true == 'true'` is false, as 'true' is not a number.
+//
+// Arrays and objects are not compared structurally: every call to fromJSON() creates a new value, so
+// `fromJSON('[1,2]') == fromJSON('[1,2]')` is false, while the same context value is equal to itself.
+// contains() uses the same rules to compare array elements.
 func (ev *EvaluationResult) Equals(rhs *EvaluationResult) bool {
+	return ev.equals(rhs, false)
+}
+
+// equals is like Equals, optionally comparing strings case-sensitively.
+func (ev *EvaluationResult) equals(rhs *EvaluationResult, caseSensitive bool) bool {
 	lv, ltype, rv, rtype := coerceTypes(ev.Value, rhs.Value)
 
 	if ltype.String() != rtype.String() {
@@ -195,8 +497,8 @@ func (ev *EvaluationResult) Equals(rhs *EvaluationResult) bool {
 
 		// Number, Number
 	case *actionlint.NumberType:
-		ld := lv.(float64)
-		rd := rv.(float64)
+		ld := convertToNumber(lv)
+		rd := convertToNumber(rv)
 		if math.IsNaN(ld) || math.IsNaN(rd) {
 			return false
 		}
@@ -205,9 +507,13 @@ func (ev *EvaluationResult) Equals(rhs *EvaluationResult) bool {
 
 		// String, String
 	case *actionlint.StringType:
-		ls := lv.(string)
-		rs := rv.(string)
-		return strings.EqualFold(ls, rs)
+		ls := toString(lv)
+		rs := toString(rv)
+		if caseSensitive {
+			return ls == rs
+		}
+
+		return equalIgnoreCase(ls, rs)
 
 		// Boolean, Boolean
 	case *actionlint.BoolType:
@@ -218,75 +524,81 @@ func (ev *EvaluationResult) Equals(rhs *EvaluationResult) bool {
 		// Object, Object
 	case *actionlint.ObjectType, *actionlint.ArrayType:
 		// Check reference equality
-		return lv == rv
+		return sameReference(lv, rv)
 	}
 
 	return false
 }
 
-func (ev *EvaluationResult) GreaterThan(rhs *EvaluationResult) bool {
-	lv, ltype, rv, rtype := coerceTypes(ev.Value, rhs.Value)
-
-	if ltype.String() != rtype.String() {
-		return false
+// newArray returns an array of the given length. Empty slices usually share the same address, which
+// would make them the same reference, so every array gets memory of its own.
+func newArray(n int) []interface{} {
+	if n == 0 {
+		return make([]interface{}, 0, 1)
 	}
 
-	switch ltype.(type) {
-	// Number, Number
-	case *actionlint.NumberType:
-		lf := lv.(float64)
-		rf := rv.(float64)
-		if math.IsNaN(lf) || math.IsNaN(rf) {
-			return false
-		}
+	return make([]interface{}, n)
+}
 
-		return lf > rf
+// distinctEmptyArrays replaces the empty arrays in v with ones created by newArray.
+func distinctEmptyArrays(v interface{}) interface{} {
+	switch vt := v.(type) {
+	case []interface{}:
+		if len(vt) == 0 {
+			return newArray(0)
+		}
 
-		// String, String
-	case *actionlint.StringType:
-		ls := lv.(string)
-		rs := rv.(string)
-		return ls >= rs
+		for i, e := range vt {
+			vt[i] = distinctEmptyArrays(e)
+		}
 
-		// Boolean, Boolean
-	case *actionlint.BoolType:
-		lb := lv.(bool)
-		rb := rv.(bool)
-		return lb && !rb
+	case ContextData:
+		for k, e := range vt {
+			vt[k] = distinctEmptyArrays(e)
+		}
 	}
 
-	return false
+	return v
 }
 
-func (ev *EvaluationResult) LessThan(rhs *EvaluationResult) bool {
-	lv, ltype, rv, rtype := coerceTypes(ev.Value, rhs.Value)
+// sameReference reports whether two maps or slices refer to the same underlying data.
+func sameReference(l interface{}, r interface{}) bool {
+	lr := reflect.ValueOf(l)
+	rr := reflect.ValueOf(r)
 
-	if ltype.String() != rtype.String() {
+	if lr.Kind() != rr.Kind() {
 		return false
 	}
 
-	switch ltype.(type) {
-	// Number, Number
-	case *actionlint.NumberType:
-		lf := lv.(float64)
-		rf := rv.(float64)
-		if math.IsNaN(lf) || math.IsNaN(rf) {
-			return false
-		}
-		return lf < rf
-
-		// String, String
-	case *actionlint.StringType:
-		ls := lv.(string)
-		rs := rv.(string)
-		return ls < rs
+	switch lr.Kind() {
+	case reflect.Map:
+		return lr.Pointer() == rr.Pointer()
 
-		// Boolean, Boolean
-	case *actionlint.BoolType:
-		lb := lv.(bool)
-		rb := rv.(bool)
-		return !lb && rb
+	case reflect.Slice:
+		return lr.Pointer() == rr.Pointer() && lr.Len() == rr.Len()
 	}
 
 	return false
 }
+
+// GreaterThan reports whether the result is greater than rhs. Both sides are converted to numbers
+// using CoerceNumber, so strings are compared numerically and comparisons involving NaN are false.
+func (ev *EvaluationResult) GreaterThan(rhs *EvaluationResult) bool {
+	return ev.CoerceNumber() > rhs.CoerceNumber()
+}
+
+// GreaterThanOrEqual reports whether the result is greater than or equal to rhs, compared like
+// GreaterThan.
+func (ev *EvaluationResult) GreaterThanOrEqual(rhs *EvaluationResult) bool {
+	return ev.CoerceNumber() >= rhs.CoerceNumber()
+}
+
+// LessThan reports whether the result is less than rhs, compared like GreaterThan.
+func (ev *EvaluationResult) LessThan(rhs *EvaluationResult) bool {
+	return ev.CoerceNumber() < rhs.CoerceNumber()
+}
+
+// LessThanOrEqual reports whether the result is less than or equal to rhs, compared like GreaterThan.
+func (ev *EvaluationResult) LessThanOrEqual(rhs *EvaluationResult) bool {
+	return ev.CoerceNumber() <= rhs.CoerceNumber()
+}
@@ -1,13 +1,270 @@
 package expr
 
 import (
+	"encoding/json"
+	"fmt"
 	"math"
+	"reflect"
 	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/rhysd/actionlint"
 )
 
+func TestNewResults(t *testing.T) {
+	arr := []interface{}{float64(1), float64(2)}
+	obj := ContextData{"a": "b"}
+
+	tests := []struct {
+		name string
+		got  *EvaluationResult
+		want *EvaluationResult
+	}{
+		{"NewNull", NewNull(), &EvaluationResult{nil, &actionlint.NullType{}}},
+		{"NewBool", NewBool(true), &EvaluationResult{true, &actionlint.BoolType{}}},
+		{"NewNumber", NewNumber(1.5), &EvaluationResult{float64(1.5), &actionlint.NumberType{}}},
+		{"NewString", NewString("abc"), &EvaluationResult{"abc", &actionlint.StringType{}}},
+		{"NewArray", NewArray(arr), &EvaluationResult{arr, &actionlint.ArrayType{Elem: &actionlint.AnyType{}}}},
+		{"NewArray - empty", NewArray([]interface{}{}), &EvaluationResult{[]interface{}{}, &actionlint.ArrayType{Elem: &actionlint.AnyType{}}}},
+		{"NewObject", NewObject(obj), &EvaluationResult{obj, getExprType(obj)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !reflect.DeepEqual(tt.got, tt.want) {
+				t.Errorf("%s() = %v, want %v", tt.name, tt.got, tt.want)
+			}
+		})
+	}
+
+	if _, ok := NewObject(obj).Type.(*actionlint.ObjectType); !ok {
+		t.Errorf("NewObject() type = %v, want object", NewObject(obj).Type)
+	}
+}
+
+func TestEvaluationResult_As(t *testing.T) {
+	if b, ok := NewBool(true).AsBool(); !ok || !b {
+		t.Errorf("AsBool() = %v, %v, want true, true", b, ok)
+	}
+	if _, ok := NewString("true").AsBool(); ok {
+		t.Errorf("AsBool() of string succeeded")
+	}
+
+	if f, ok := NewNumber(2).AsNumber(); !ok || f != 2 {
+		t.Errorf("AsNumber() = %v, %v, want 2, true", f, ok)
+	}
+	if _, ok := NewString("2").AsNumber(); ok {
+		t.Errorf("AsNumber() of string succeeded")
+	}
+
+	if s, ok := NewString("abc").AsString(); !ok || s != "abc" {
+		t.Errorf("AsString() = %v, %v, want abc, true", s, ok)
+	}
+	if s, ok := (&EvaluationResult{secretString("abc"), &actionlint.StringType{}}).AsString(); !ok || s != "***" {
+		t.Errorf("AsString() of secret = %v, %v, want ***, true", s, ok)
+	}
+	if _, ok := NewNull().AsString(); ok {
+		t.Errorf("AsString() of null succeeded")
+	}
+	if _, ok := NewArray([]interface{}{"a"}).AsString(); ok {
+		t.Errorf("AsString() of array succeeded")
+	}
+}
+
+func TestEvaluationResult_MarshalJSON(t *testing.T) {
+	tests := []struct {
+		name   string
+		result *EvaluationResult
+		want   string
+	}{
+		{"null", NewNull(), `null`},
+		{"true", NewBool(true), `true`},
+		{"false", NewBool(false), `false`},
+		{"integer", NewNumber(3), `3`},
+		{"float", NewNumber(1.5), `1.5`},
+		{"json number", &EvaluationResult{json.Number("1.10"), &actionlint.NumberType{}}, `1.10`},
+		{"string", NewString("a\"b"), `"a\"b"`},
+		{"empty string", NewString(""), `""`},
+		{"secret", &EvaluationResult{secretString("token"), &actionlint.StringType{}}, `"***"`},
+		{"empty array", NewArray([]interface{}{}), `[]`},
+		{"array", NewArray([]interface{}{1.0, "a", nil, true}), `[1,"a",null,true]`},
+		{"object", NewObject(ContextData{"b": 1.0, "a": "x"}), `{"a":"x","b":1}`},
+		{"nested", NewObject(ContextData{"a": []interface{}{ContextData{"b": nil}}, "c": ContextData{}}), `{"a":[{"b":null}],"c":{}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.result.MarshalJSON()
+			if err != nil {
+				t.Fatalf("MarshalJSON() error = %v", err)
+			}
+
+			if string(got) != tt.want {
+				t.Errorf("MarshalJSON() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	// Results are encoded as their values when part of other data
+	result, err := Evaluate("fromJSON('{\"os\": [\"linux\"]}')", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(map[string]interface{}{"result": result, "value": *result, "missing": (*EvaluationResult)(nil)})
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+
+	if want := `{"missing":null,"result":{"os":["linux"]},"value":{"os":["linux"]}}`; string(data) != want {
+		t.Errorf("json.Marshal() = %s, want %s", data, want)
+	}
+
+	if _, err := NewNumber(math.Inf(1)).MarshalJSON(); err == nil {
+		t.Errorf("MarshalJSON() of infinity did not fail")
+	}
+}
+
+func TestEvaluationResult_Primitive(t *testing.T) {
+	tests := []struct {
+		result *EvaluationResult
+		want   bool
+	}{
+		{NewNull(), true},
+		{NewBool(false), true},
+		{NewNumber(0), true},
+		{NewString(""), true},
+		{&EvaluationResult{secretString("abc"), &actionlint.StringType{}}, true},
+		{NewArray([]interface{}{}), false},
+		{NewArray([]interface{}{nil}), false},
+		{NewObject(ContextData{}), false},
+	}
+	for _, tt := range tests {
+		if got := tt.result.Primitive(); got != tt.want {
+			t.Errorf("Primitive() of %#v = %v, want %v", tt.result.Value, got, tt.want)
+		}
+	}
+}
+
+func TestEvaluationResult_Clone(t *testing.T) {
+	original, err := Evaluate(`fromJSON('{"a": [1, {"b": "c"}], "d": {"e": true}}')`, nil)
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+
+	want := ContextData{
+		"a": []interface{}{1.0, ContextData{"b": "c"}},
+		"d": ContextData{"e": true},
+	}
+
+	clone := original.Clone()
+	if !reflect.DeepEqual(clone.Value, want) || clone.Type != original.Type {
+		t.Fatalf("Clone() = %v, want %v", clone.Value, want)
+	}
+
+	obj := clone.Value.(ContextData)
+	obj["x"] = "y"
+	obj["a"].([]interface{})[0] = 2.0
+	obj["a"].([]interface{})[1].(ContextData)["b"] = "changed"
+	obj["d"].(ContextData)["e"] = false
+
+	if !reflect.DeepEqual(original.Value, want) {
+		t.Errorf("original = %v after modifying clone, want %v", original.Value, want)
+	}
+
+	if clone.Equals(original) {
+		t.Errorf("Equals() of clone = true, want false")
+	}
+
+	for _, r := range []*EvaluationResult{NewNull(), NewBool(true), NewNumber(1), NewString("a")} {
+		if c := r.Clone(); c == r || !c.Equals(r) {
+			t.Errorf("Clone() of %v = %v, want equal copy", r.Value, c.Value)
+		}
+	}
+}
+
+func TestEvaluationResult_Decode(t *testing.T) {
+	result, err := Evaluate(`fromJSON('{"name": "build", "runs-on": ["ubuntu", "windows"], "timeout": 10, "env": {"CI": "true"}}')`, nil)
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+
+	type job struct {
+		Name    string            `json:"name"`
+		RunsOn  []string          `json:"runs-on"`
+		Timeout int               `json:"timeout"`
+		Env     map[string]string `json:"env"`
+		Missing *bool             `json:"missing"`
+	}
+
+	var j job
+	if err := result.Decode(&j); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+
+	want := job{Name: "build", RunsOn: []string{"ubuntu", "windows"}, Timeout: 10, Env: map[string]string{"CI": "true"}}
+	if !reflect.DeepEqual(j, want) {
+		t.Errorf("Decode() = %+v, want %+v", j, want)
+	}
+
+	var obj map[string]interface{}
+	if err := result.Decode(&obj); err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if !reflect.DeepEqual(obj, result.Value) {
+		t.Errorf("Decode() = %v, want %v", obj, result.Value)
+	}
+
+	var s string
+	if err := NewString("abc").Decode(&s); err != nil || s != "abc" {
+		t.Errorf("Decode() = %q, %v, want abc", s, err)
+	}
+	if err := (&EvaluationResult{secretString("abc"), &actionlint.StringType{}}).Decode(&s); err != nil || s != "***" {
+		t.Errorf("Decode() of secret = %q, %v, want ***", s, err)
+	}
+
+	secrets := MapContext{"secrets": ContextData{"t": "abc", "list": []interface{}{"def"}}}
+	for _, input := range []string{"secrets.t", "secrets", "secrets.list"} {
+		secret, err := (&Evaluator{MaskSecrets: true}).Evaluate(input, secrets)
+		if err != nil {
+			t.Fatalf("Evaluate() error = %v", err)
+		}
+
+		var v interface{}
+		if err := secret.Decode(&v); err != nil {
+			t.Fatalf("Decode() error = %v", err)
+		}
+
+		if got := fmt.Sprint(v); strings.Contains(got, "abc") || strings.Contains(got, "def") {
+			t.Errorf("Decode() of %s into interface{} = %v, want masked secrets", input, v)
+		}
+	}
+
+	var f float64
+	if err := NewNumber(1.5).Decode(&f); err != nil || f != 1.5 {
+		t.Errorf("Decode() = %v, %v, want 1.5", f, err)
+	}
+
+	b := true
+	if err := NewNull().Decode(&b); err != nil || !b {
+		t.Errorf("Decode() of null = %v, %v, want unchanged value", b, err)
+	}
+
+	var arr []interface{}
+	if err := NewArray([]interface{}{"a", 1.0}).Decode(&arr); err != nil || !reflect.DeepEqual(arr, []interface{}{"a", 1.0}) {
+		t.Errorf("Decode() = %v, %v, want [a 1]", arr, err)
+	}
+
+	if err := NewString("abc").Decode(&f); err == nil {
+		t.Errorf("Decode() of string into float64 did not fail")
+	}
+	if err := NewString("abc").Decode(s); err == nil {
+		t.Errorf("Decode() into non-pointer did not fail")
+	}
+	if err := NewString("abc").Decode((*string)(nil)); err == nil {
+		t.Errorf("Decode() into nil pointer did not fail")
+	}
+}
+
 func TestEvaluationResult_CoerceString(t *testing.T) {
 	type fields struct {
 		Value interface{}
@@ -22,8 +279,15 @@ func TestEvaluationResult_CoerceString(t *testing.T) {
 		{fields{true, &actionlint.BoolType{}}, "true"},
 		{fields{float64(-0), &actionlint.NumberType{}}, "0"},
 		{fields{float64(1.234), &actionlint.NumberType{}}, "1.234"},
+		{fields{float64(0), &actionlint.NumberType{}}, "0"},
+		{fields{float64(3), &actionlint.NumberType{}}, "3"},
+		{fields{float64(3.5), &actionlint.NumberType{}}, "3.5"},
+		{fields{float64(1e21), &actionlint.NumberType{}}, "1E+21"},
 		{fields{"test", &actionlint.StringType{}}, "test"},
-		{fields{[]interface{}{1, 2}, &actionlint.ArrayType{Elem: &actionlint.NumberType{}}}, "array<number>"},
+		{fields{[]interface{}{1, 2}, &actionlint.ArrayType{Elem: &actionlint.NumberType{}}}, "Array"},
+		{fields{[]interface{}{}, &actionlint.ArrayType{Elem: &actionlint.AnyType{}, Deref: true}}, "Array"},
+		{fields{ContextData{"a": "b"}, getExprType(ContextData{"a": "b"})}, "Object"},
+		{fields{ContextData{}, &actionlint.ObjectType{}}, "Object"},
 	}
 	for _, tt := range tests {
 		name := tt.fields.Type.String() + " " + tt.want
@@ -40,6 +304,41 @@ func TestEvaluationResult_CoerceString(t *testing.T) {
 	}
 }
 
+func TestEvaluationResult_String(t *testing.T) {
+	tests := []struct {
+		result *EvaluationResult
+		want   string
+	}{
+		{NewNull(), "Null"},
+		{NewBool(true), "Bool(true)"},
+		{NewBool(false), "Bool(false)"},
+		{NewNumber(1.5), "Number(1.5)"},
+		{NewNumber(math.Copysign(0, -1)), "Number(0)"},
+		{NewNumber(math.NaN()), "Number(NaN)"},
+		{&EvaluationResult{json.Number("1.10"), &actionlint.NumberType{}}, "Number(1.10)"},
+		{NewString("abc"), `String("abc")`},
+		{NewString(""), `String("")`},
+		{NewString("a\"b\n"), `String("a\"b\n")`},
+		{&EvaluationResult{secretString("token"), &actionlint.StringType{}}, `String("***")`},
+		{NewArray([]interface{}{1.0, "a", nil}), "Array[3]"},
+		{NewArray([]interface{}{}), "Array[0]"},
+		{NewObject(ContextData{"a": 1.0, "b": 2.0}), "Object[2]"},
+		{nil, "<nil>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := tt.result.String(); got != tt.want {
+				t.Errorf("EvaluationResult.String() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// String is used when formatting results, unlike CoerceString
+	if got := fmt.Sprint(NewString("")); got != `String("")` {
+		t.Errorf("fmt.Sprint() = %v, want %v", got, `String("")`)
+	}
+}
+
 func TestEvaluationResult_Falsy(t *testing.T) {
 	type fields struct {
 		Value interface{}
@@ -72,3 +371,333 @@ func TestEvaluationResult_Falsy(t *testing.T) {
 		})
 	}
 }
+
+func TestEvaluationResult_CoerceNumber(t *testing.T) {
+	type fields struct {
+		Value interface{}
+		Type  actionlint.ExprType
+	}
+	tests := []struct {
+		name   string
+		fields fields
+		want   float64
+	}{
+		{"null", fields{nil, &actionlint.NullType{}}, 0},
+		{"true", fields{true, &actionlint.BoolType{}}, 1},
+		{"false", fields{false, &actionlint.BoolType{}}, 0},
+		{"number", fields{float64(1.5), &actionlint.NumberType{}}, 1.5},
+		{"empty string", fields{"", &actionlint.StringType{}}, 0},
+		{"hex string", fields{"0x1F", &actionlint.StringType{}}, 31},
+		{"upper case hex string", fields{"0X1F", &actionlint.StringType{}}, 31},
+		{"binary string", fields{"0b11", &actionlint.StringType{}}, 3},
+		{"underscore string", fields{"1_0", &actionlint.StringType{}}, math.NaN()},
+		{"hex float string", fields{"0x1p3", &actionlint.StringType{}}, math.NaN()},
+		{"inf string", fields{"inf", &actionlint.StringType{}}, math.NaN()},
+		{"infinity string", fields{"infinity", &actionlint.StringType{}}, math.NaN()},
+		{"negative inf string", fields{"-inf", &actionlint.StringType{}}, math.NaN()},
+		{"Infinity string", fields{"Infinity", &actionlint.StringType{}}, math.Inf(1)},
+		{"exponent string", fields{"1e3", &actionlint.StringType{}}, 1000},
+		{"padded string", fields{" 42 ", &actionlint.StringType{}}, 42},
+		{"non-numeric string", fields{"abc", &actionlint.StringType{}}, math.NaN()},
+		{"array", fields{[]interface{}{float64(1)}, &actionlint.ArrayType{Elem: &actionlint.NumberType{}}}, math.NaN()},
+		{"object", fields{ContextData{}, &actionlint.ObjectType{}}, math.NaN()},
+		{"empty array", fields{[]interface{}{}, &actionlint.ArrayType{Elem: &actionlint.AnyType{}}}, math.NaN()},
+		{"filtered array", fields{[]interface{}{float64(1)}, &actionlint.ArrayType{Elem: &actionlint.AnyType{}, Deref: true}}, math.NaN()},
+		{"partially numeric string", fields{"1a", &actionlint.StringType{}}, math.NaN()},
+		{"secret string", fields{secretString("2"), &actionlint.StringType{}}, 2},
+		{"non-numeric secret string", fields{secretString("abc"), &actionlint.StringType{}}, math.NaN()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ev := &EvaluationResult{
+				Value: tt.fields.Value,
+				Type:  tt.fields.Type,
+			}
+			got := ev.CoerceNumber()
+			if math.IsNaN(tt.want) {
+				if !math.IsNaN(got) {
+					t.Errorf("EvaluationResult.CoerceNumber() = %v, want %v", got, tt.want)
+				}
+			} else if got != tt.want {
+				t.Errorf("EvaluationResult.CoerceNumber() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluationResult_IsTruthy(t *testing.T) {
+	for _, r := range []*EvaluationResult{NewNull(), NewBool(false), NewNumber(0), NewString("")} {
+		if r.IsTruthy() {
+			t.Errorf("IsTruthy() of %#v = true, want false", r.Value)
+		}
+	}
+
+	for _, r := range []*EvaluationResult{NewBool(true), NewNumber(-1), NewString("false"), NewArray([]interface{}{}), NewObject(ContextData{})} {
+		if !r.IsTruthy() {
+			t.Errorf("IsTruthy() of %#v = false, want true", r.Value)
+		}
+	}
+}
+
+func TestEvaluationResult_CoerceBool(t *testing.T) {
+	type fields struct {
+		Value interface{}
+		Type  actionlint.ExprType
+	}
+	tests := []struct {
+		name   string
+		fields fields
+		want   bool
+	}{
+		{"null", fields{nil, &actionlint.NullType{}}, false},
+		{"true", fields{true, &actionlint.BoolType{}}, true},
+		{"false", fields{false, &actionlint.BoolType{}}, false},
+		{"zero", fields{float64(0), &actionlint.NumberType{}}, false},
+		{"negative zero", fields{math.Copysign(0, -1), &actionlint.NumberType{}}, false},
+		{"NaN", fields{math.NaN(), &actionlint.NumberType{}}, false},
+		{"number", fields{float64(-1.5), &actionlint.NumberType{}}, true},
+		{"empty string", fields{"", &actionlint.StringType{}}, false},
+		{"string", fields{"false", &actionlint.StringType{}}, true},
+		{"empty array", fields{[]interface{}{}, &actionlint.ArrayType{Elem: &actionlint.AnyType{}}}, true},
+		{"empty object", fields{ContextData{}, &actionlint.ObjectType{}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ev := &EvaluationResult{
+				Value: tt.fields.Value,
+				Type:  tt.fields.Type,
+			}
+			if got := ev.CoerceBool(); got != tt.want {
+				t.Errorf("EvaluationResult.CoerceBool() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluationResult_Equals(t *testing.T) {
+	obj := ContextData{}
+	arr := []interface{}{}
+
+	tests := []struct {
+		name string
+		lhs  interface{}
+		rhs  interface{}
+		want bool
+	}{
+		// Same types
+		{"null, null", nil, nil, true},
+		{"true, true", true, true, true},
+		{"true, false", true, false, false},
+		{"1, 1", float64(1), float64(1), true},
+		{"1, 2", float64(1), float64(2), false},
+		{"NaN, NaN", math.NaN(), math.NaN(), false},
+		{"'abc', 'ABC'", "abc", "ABC", true},
+		{"'abc', 'abd'", "abc", "abd", false},
+		{"same object", obj, obj, true},
+		{"different objects", obj, ContextData{}, false},
+
+		// Mixed types are coerced to numbers
+		{"'1', 1", "1", float64(1), true},
+		{"1, '1'", float64(1), "1", true},
+		{"'0x10', 16", "0x10", float64(16), true},
+		{"'abc', 0", "abc", float64(0), false},
+		{"true, 1", true, float64(1), true},
+		{"false, 0", false, float64(0), true},
+		{"true, 2", true, float64(2), false},
+		{"null, 0", nil, float64(0), true},
+		{"null, ''", nil, "", true},
+		{"null, false", nil, false, true},
+		{"'', 0", "", float64(0), true},
+		{"'', false", "", false, true},
+		{"' ', 0", " ", float64(0), true},
+
+		// Booleans compared with strings are converted to numbers on both sides
+		{"true, 'true'", true, "true", false},
+		{"false, 'false'", false, "false", false},
+		{"true, '1'", true, "1", true},
+		{"true, ' 1 '", true, " 1 ", true},
+		{"true, '1.0'", true, "1.0", true},
+		{"true, '0x1'", true, "0x1", true},
+		{"true, '2'", true, "2", false},
+		{"false, '0'", false, "0", true},
+		{"false, ''", false, "", true},
+		{"true, ''", true, "", false},
+		{"false, 'abc'", false, "abc", false},
+		{"true, 'abc'", true, "abc", false},
+
+		// Booleans compared with numbers and null
+		{"false, -0", false, math.Copysign(0, -1), true},
+		{"true, 1.5", true, float64(1.5), false},
+		{"true, NaN", true, math.NaN(), false},
+		{"true, null", true, nil, false},
+
+		// null compared with strings
+		{"null, 'null'", nil, "null", false},
+		{"null, '0'", nil, "0", true},
+		{"null, 'abc'", nil, "abc", false},
+
+		// Numbers compared with strings
+		{"1, 'abc'", float64(1), "abc", false},
+		{"NaN, 'NaN'", math.NaN(), "NaN", false},
+		{"16, '1.6e1'", float64(16), "1.6e1", true},
+		{"1, '1.0'", float64(1), "1.0", true},
+		{"1.5, '1.5'", float64(1.5), "1.5", true},
+		{"1.5, '1.50'", float64(1.5), "1.50", true},
+		{"1, '1.5'", float64(1), "1.5", false},
+		{"json 1.0, '1'", json.Number("1.0"), "1", true},
+
+		// Arrays and objects are never equal to primitives
+		{"object, 0", obj, float64(0), false},
+		{"array, ''", arr, "", false},
+		{"array, null", arr, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lhs := &EvaluationResult{tt.lhs, getExprType(tt.lhs)}
+			rhs := &EvaluationResult{tt.rhs, getExprType(tt.rhs)}
+
+			if got := lhs.Equals(rhs); got != tt.want {
+				t.Errorf("EvaluationResult.Equals() = %v, want %v", got, tt.want)
+			}
+
+			if got := rhs.Equals(lhs); got != tt.want {
+				t.Errorf("EvaluationResult.Equals() reversed = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluationResult_Equals_Composite(t *testing.T) {
+	ctx := MapContext{
+		"matrix": ContextData{
+			"list": []interface{}{float64(1), float64(2)},
+			"obj":  ContextData{"a": float64(1)},
+		},
+	}
+
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{"fromJSON('[1,2]') == fromJSON('[1,2]')", false},
+		{"fromJSON('{\"a\":1}') == fromJSON('{\"a\":1}')", false},
+		{"fromJSON('[1,2]') != fromJSON('[1,2]')", true},
+		{"matrix.list == matrix.list", true},
+		{"matrix.obj == matrix['obj']", true},
+		{"matrix.list == fromJSON('[1,2]')", false},
+		{"contains(fromJSON('[[1,2]]'), fromJSON('[1,2]'))", false},
+		{"fromJSON('[1,2]')[0] == fromJSON('[1,2]')[0]", true},
+		{"fromJSON('[1]') == fromJSON('[1]')", false},
+		{"fromJSON('[]') == fromJSON('[]')", false},
+		{"fromJSON('{}') == fromJSON('{}')", false},
+		{"fromJSON('[]') == fromJSON('{}')", false},
+		{"matrix.list == matrix.obj", false},
+		{"matrix.list != matrix.list", false},
+		{"matrix.*.a == matrix.*.a", false},
+		{"fromJSON('[1]') == 'Array'", false},
+		{"fromJSON('{}') == 'Object'", false},
+		{"fromJSON('[1]') < fromJSON('[2]')", false},
+		{"fromJSON('[1]') >= fromJSON('[1]')", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := Evaluate(tt.input, ctx)
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+
+			if got.Value != tt.want {
+				t.Errorf("Evaluate() = %v, want %v", got.Value, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluationResult_Equals_EmptyContextArrays(t *testing.T) {
+	// Empty arrays decoded by json.Unmarshal share the same address
+	var event ContextData
+	if err := json.Unmarshal([]byte(`{"labels": [], "assignees": [], "lists": [[], []], "Empty": []}`), &event); err != nil {
+		t.Fatal(err)
+	}
+	var list []interface{}
+	if err := json.Unmarshal([]byte(`[]`), &list); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := MapContext{"github": ContextData{"event": event}, "matrix": list, "inputs": list}
+
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{"github.event.labels == github.event.assignees", false},
+		{"github.event.labels != github.event.assignees", true},
+		{"github.event.labels == github.event.labels", true},
+		{"github.event.labels == github.event['LABELS']", true},
+		{"github.event.lists[0] == github.event.lists[1]", false},
+		{"github.event.lists[0] == github.event.lists[0]", true},
+		{"github.event.lists.*[0] == github.event.lists[0]", true},
+		{"github.event.lists.*[1] == github.event.lists[0]", false},
+		{"github.event.empty == github.event.Empty", true},
+		{"matrix == inputs", false},
+		{"matrix == matrix", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := Evaluate(tt.input, ctx)
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+
+			if got.Value != tt.want {
+				t.Errorf("Evaluate() = %v, want %v", got.Value, tt.want)
+			}
+		})
+	}
+
+	// The contexts are not modified
+	if labels := event["labels"].([]interface{}); cap(labels) != 0 {
+		t.Errorf("labels were replaced")
+	}
+}
+
+func Test_getExprType(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    interface{}
+		want     actionlint.ExprType
+		wantDeep actionlint.ExprType
+	}{
+		{"null", nil, &actionlint.NullType{}, nil},
+		{"bool", true, &actionlint.BoolType{}, nil},
+		{"number", float64(1), &actionlint.NumberType{}, nil},
+		{"string", "a", &actionlint.StringType{}, nil},
+		{"empty array", []interface{}{}, &actionlint.ArrayType{Elem: &actionlint.AnyType{}}, nil},
+		{"number array", []interface{}{float64(1), float64(2)}, &actionlint.ArrayType{Elem: &actionlint.AnyType{}}, &actionlint.ArrayType{Elem: &actionlint.NumberType{}}},
+		{"mixed array", []interface{}{float64(1), "a"}, &actionlint.ArrayType{Elem: &actionlint.AnyType{}}, nil},
+		{"object", ContextData{"a": "b"}, &actionlint.ObjectType{
+			Props:  map[string]actionlint.ExprType{},
+			Mapped: &actionlint.AnyType{},
+		}, &actionlint.ObjectType{
+			Props:  map[string]actionlint.ExprType{"a": &actionlint.StringType{}},
+			Mapped: &actionlint.AnyType{},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getExprType(tt.value); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getExprType() = %v, want %v", got, tt.want)
+			}
+
+			// Only deep types differ for arrays and objects with elements
+			wantDeep := tt.wantDeep
+			if wantDeep == nil {
+				wantDeep = tt.want
+			}
+
+			if got := getDeepExprType(tt.value); !reflect.DeepEqual(got, wantDeep) {
+				t.Errorf("getDeepExprType() = %v, want %v", got, wantDeep)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package expr
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/rhysd/actionlint"
+)
+
+// secretMask replaces secret values when they are converted to strings.
+const secretMask = "***"
+
+// secretString is a string value read from the `secrets` context while masking is enabled. It
+// behaves like a regular string in comparisons and function calls, but is replaced by secretMask
+// whenever it is converted to a string or serialized to JSON.
+type secretString string
+
+func (s secretString) MarshalJSON() ([]byte, error) {
+	return json.Marshal(secretMask)
+}
+
+// maskedString is a string computed from secrets by a function like format(). Like secretString, its
+// actual value is used in comparisons and function calls, and its masked value, with every secret
+// replaced by secretMask, whenever it is converted to a string or serialized to JSON.
+type maskedString struct {
+	value  string
+	masked string
+}
+
+func (s maskedString) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.masked)
+}
+
+// maskedValue returns the masked value of a secret string, ok is false for all other values.
+func maskedValue(v interface{}) (masked string, ok bool) {
+	switch vt := v.(type) {
+	case secretString:
+		return secretMask, true
+
+	case maskedString:
+		return vt.masked, true
+	}
+
+	return "", false
+}
+
+// newStringResult returns a string result with the given actual and masked values, holding a
+// maskedString if they differ.
+func newStringResult(value, masked string) *EvaluationResult {
+	if value == masked {
+		return NewString(value)
+	}
+
+	return &EvaluationResult{maskedString{value, masked}, &actionlint.StringType{}}
+}
+
+// isSecret reports whether the result is a secret string.
+func (ev *EvaluationResult) isSecret() bool {
+	_, ok := maskedValue(ev.Value)
+	return ok
+}
+
+// maskSecrets returns a copy of the given value with all strings marked as secret.
+func maskSecrets(v interface{}) interface{} {
+	switch vt := v.(type) {
+	case string:
+		return secretString(vt)
+
+	case maskedString:
+		return secretString(vt.value)
+
+	case ContextData:
+		r := make(ContextData, len(vt))
+		for k, v := range vt {
+			r[k] = maskSecrets(v)
+		}
+
+		return r
+
+	case []interface{}:
+		r := newArray(len(vt))
+		for i, v := range vt {
+			r[i] = maskSecrets(v)
+		}
+
+		return r
+	}
+
+	return v
+}
+
+// unmaskSecrets returns a copy of the given value with all secret strings turned into regular ones,
+// for functions that compute their result from the actual value.
+func unmaskSecrets(v interface{}) interface{} {
+	switch vt := v.(type) {
+	case secretString, maskedString:
+		return toString(vt)
+
+	case ContextData:
+		r := make(ContextData, len(vt))
+		for k, v := range vt {
+			r[k] = unmaskSecrets(v)
+		}
+
+		return r
+
+	case []interface{}:
+		r := newArray(len(vt))
+		for i, v := range vt {
+			r[i] = unmaskSecrets(v)
+		}
+
+		return r
+	}
+
+	return v
+}
+
+// containsSecret reports whether v is a secret string or an array or object containing one.
+func containsSecret(v interface{}) bool {
+	switch vt := v.(type) {
+	case secretString, maskedString:
+		return true
+
+	case ContextData:
+		for _, v := range vt {
+			if containsSecret(v) {
+				return true
+			}
+		}
+
+	case []interface{}:
+		for _, v := range vt {
+			if containsSecret(v) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// secretValues appends the actual values of all non-empty secret strings in v to values.
+func secretValues(values []string, v interface{}) []string {
+	switch vt := v.(type) {
+	case secretString, maskedString:
+		if s := toString(vt); s != "" {
+			values = append(values, s)
+		}
+
+	case ContextData:
+		for _, v := range vt {
+			values = secretValues(values, v)
+		}
+
+	case []interface{}:
+		for _, v := range vt {
+			values = secretValues(values, v)
+		}
+	}
+
+	return values
+}
+
+// maskError returns err with all occurrences of the given secret values in its message replaced by
+// secretMask, or err itself if the message does not contain any of them.
+func maskError(err error, secrets []string) error {
+	msg := err.Error()
+	for _, s := range secrets {
+		msg = strings.ReplaceAll(msg, s, secretMask)
+	}
+
+	if msg == err.Error() {
+		return err
+	}
+
+	return errors.New(msg)
+}
+
+// toString returns the actual value of a string, including secret ones.
+func toString(v interface{}) string {
+	switch vt := v.(type) {
+	case secretString:
+		return string(vt)
+
+	case maskedString:
+		return vt.value
+	}
+
+	return v.(string)
+}
@@ -0,0 +1,106 @@
+package expr
+
+import (
+	"testing"
+)
+
+func TestEvaluator_MaskSecrets(t *testing.T) {
+	ctx := MapContext{
+		"secrets": ContextData{
+			"TOKEN":  "s3cr3t",
+			"JSON":   `{"name": "s3cr3t", "count": 2}`,
+			"FORMAT": "<{0}>",
+		},
+		"env": ContextData{
+			"TOKEN": "plain",
+		},
+	}
+
+	tests := []struct {
+		input    string
+		want     interface{}
+		wantMask interface{}
+	}{
+		{"format('token={0}', secrets.TOKEN)", "token=s3cr3t", "token=***"},
+		{"format('token={0}', env.TOKEN)", "token=plain", "token=plain"},
+		{"toJSON(secrets)", "{\n  \"FORMAT\": \"<{0}>\",\n  \"JSON\": \"{\\\"name\\\": \\\"s3cr3t\\\", \\\"count\\\": 2}\",\n  \"TOKEN\": \"s3cr3t\"\n}", "{\n  \"FORMAT\": \"***\",\n  \"JSON\": \"***\",\n  \"TOKEN\": \"***\"\n}"},
+		{"toJSON(secrets.TOKEN)", "\"s3cr3t\"", "\"***\""},
+		{"join(fromJSON('[1,2]'), secrets.TOKEN)", "1s3cr3t2", "1***2"},
+		{"secrets.TOKEN == 's3cr3t'", true, true},
+		{"startsWith(secrets.TOKEN, 's3')", true, true},
+		{"secrets.token != ''", true, true},
+
+		// Functions compute their result from the actual value of secrets, only the result is masked
+		{"contains(format('{0}', secrets.TOKEN), 's3')", true, true},
+		{"format('{0}', secrets.TOKEN) == 's3cr3t'", true, true},
+		{"format('{0}', secrets.TOKEN) == '***'", false, false},
+		{"format('{0}-{0}', secrets.TOKEN)", "s3cr3t-s3cr3t", "***-***"},
+		{"format(secrets.FORMAT, 'x')", "<x>", "***"},
+		{"fromJSON(secrets.JSON).count", float64(2), float64(2)},
+		{"fromJSON(secrets.JSON).name", "s3cr3t", "***"},
+		{"fromJSON(secrets.JSON).name == 's3cr3t'", true, true},
+		{"toJSON(fromJSON(secrets.JSON))", "{\n  \"count\": 2,\n  \"name\": \"s3cr3t\"\n}", "{\n  \"count\": 2,\n  \"name\": \"***\"\n}"},
+		{"join(fromJSON('[1,2]'), format('-{0}-', secrets.TOKEN))", "1-s3cr3t-2", "1-***-2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := Evaluate(tt.input, ctx)
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+
+			if got.Value != tt.want {
+				t.Errorf("Evaluate() = %v, want %v", got.Value, tt.want)
+			}
+
+			ev := &Evaluator{MaskSecrets: true}
+			got, err = ev.Evaluate(tt.input, ctx)
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+
+			// Masked strings are only masked when converted to strings
+			if s, ok := got.AsString(); ok {
+				if s != tt.wantMask {
+					t.Errorf("Evaluate() with masked secrets = %v, want %v", s, tt.wantMask)
+				}
+			} else if got.Value != tt.wantMask {
+				t.Errorf("Evaluate() with masked secrets = %v, want %v", got.Value, tt.wantMask)
+			}
+		})
+	}
+}
+
+func TestEvaluator_MaskSecrets_CoerceString(t *testing.T) {
+	ev := &Evaluator{MaskSecrets: true}
+	got, err := ev.Evaluate("secrets.TOKEN", MapContext{"secrets": ContextData{"TOKEN": "s3cr3t"}})
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+
+	if s := got.CoerceString(); s != "***" {
+		t.Errorf("EvaluationResult.CoerceString() = %v, want %v", s, "***")
+	}
+}
+
+func TestEvaluator_MaskSecrets_Errors(t *testing.T) {
+	ev := &Evaluator{MaskSecrets: true}
+	ctx := MapContext{"secrets": ContextData{"TOKEN": "s3cr3t", "FORMAT": "s3cr3t{5}"}}
+
+	tests := []struct {
+		input   string
+		wantErr string
+	}{
+		{"fromJSON(secrets.TOKEN)", "error calling fromJSON: could not parse JSON: masked secret is not valid JSON"},
+		{"format(secrets.FORMAT)", "error calling format: invalid format string, index 5 out of range for 0 argument(s): ***"},
+		{"format(format('{0}{{5}}', secrets.TOKEN))", "error calling format: invalid format string, index 5 out of range for 0 argument(s): ***"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := ev.Evaluate(tt.input, ctx)
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("Evaluate() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
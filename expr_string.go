@@ -0,0 +1,59 @@
+package expr
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rhysd/actionlint"
+)
+
+// EvaluateString evaluates all expressions embedded in s using the default evaluator.
+func EvaluateString(s string, context Context) (string, error) {
+	return (&Evaluator{}).EvaluateString(s, context)
+}
+
+// EvaluateString evaluates all `${{ }}` expressions embedded in s, like GitHub does for workflow keys
+// such as `run` or `name`. Each result is converted to a string and replaces its expression, text
+// outside of expressions is kept as is.
+//
+// There is no escape sequence for `${{`. Like on GitHub, a literal `${{` can be produced with an
+// expression returning it as a string: `${{ '${{' }}`.
+func (e *Evaluator) EvaluateString(s string, context Context) (string, error) {
+	return evaluateString(s, func(expr string) (*EvaluationResult, error) {
+		return e.Evaluate(expr, context)
+	})
+}
+
+// evaluateString replaces the expressions embedded in s by their results, evaluated with evaluate.
+func evaluateString(s string, evaluate func(expr string) (*EvaluationResult, error)) (string, error) {
+	var sb strings.Builder
+
+	offset := 0
+	for {
+		start := strings.Index(s[offset:], "${{")
+		if start < 0 {
+			sb.WriteString(s[offset:])
+			break
+		}
+
+		start += offset
+		sb.WriteString(s[offset:start])
+
+		// The lexer stops at the closing braces, which may also appear in string literals
+		src := s[start+len("${{"):]
+		_, end, lerr := actionlint.LexExpression(src)
+		if lerr != nil {
+			return "", fmt.Errorf("could not parse expression at offset %d: %s", start, lerr.Message)
+		}
+
+		result, err := evaluate(src[:end-len("}}")])
+		if err != nil {
+			return "", err
+		}
+
+		sb.WriteString(result.CoerceString())
+		offset = start + len("${{") + end
+	}
+
+	return sb.String(), nil
+}
@@ -0,0 +1,63 @@
+package expr
+
+import "testing"
+
+func TestEvaluateString(t *testing.T) {
+	ctx := MapContext{
+		"github": ContextData{
+			"sha": "abc123",
+			"ref": "refs/heads/main",
+		},
+		"matrix": ContextData{
+			"node":  float64(16),
+			"debug": false,
+		},
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"empty", "", ""},
+		{"no expressions", "echo hello", "echo hello"},
+		{"only expression", "${{ github.sha }}", "abc123"},
+		{"one expression", "prefix-${{ github.sha }}-suffix", "prefix-abc123-suffix"},
+		{"multiple expressions", "${{ github.sha }} on ${{ github.ref }} with node ${{matrix.node}}", "abc123 on refs/heads/main with node 16"},
+		{"adjacent expressions", "${{ github.sha }}${{ github.sha }}", "abc123abc123"},
+		{"closing braces in string", "${{ format('{0}}}', github.sha) }}", "abc123}"},
+		{"boolean result", "is main: ${{ github.ref == 'refs/heads/main' }}", "is main: true"},
+		{"boolean literals", "${{ true }}/${{ false }}", "true/false"},
+		{"boolean context value", "debug=${{ matrix.debug }}", "debug=false"},
+		{"null result", "[${{ github.unknown }}]", "[]"},
+		{"escaped", "${{ '${{' }} github.sha }}", "${{ github.sha }}"},
+		{"unmatched closing braces", "}} ${{ 1 }} }}", "}} 1 }}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := EvaluateString(tt.input, ctx)
+			if err != nil {
+				t.Fatalf("EvaluateString() error = %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("EvaluateString() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateString_Errors(t *testing.T) {
+	tests := []string{
+		"prefix-${{ github.sha",
+		"${{ github. }}",
+		"${{ fromJSON('{') }}",
+	}
+	for _, input := range tests {
+		t.Run(input, func(t *testing.T) {
+			if got, err := EvaluateString(input, nil); err == nil {
+				t.Errorf("EvaluateString() = %q, want error", got)
+			}
+		})
+	}
+}
@@ -1,24 +1,12 @@
 package expr
 
-import (
-	"fmt"
-
-	"github.com/rhysd/actionlint"
-)
+import "fmt"
 
 func ExampleEvaluate() {
 	expression := "input.foo <= input.bar"
 
-	// Lex & Parse
-	lexer := actionlint.NewExprLexer(expression + "}}")
-	parser := actionlint.NewExprParser()
-	n, perr := parser.Parse(lexer)
-	if perr != nil {
-		panic(perr)
-	}
-
-	// Evaluate expressions
-	result, err := Evaluate(n, ContextData{
+	// Parse & evaluate expression
+	result, err := Evaluate(expression, MapContext{
 		"input": ContextData{
 			"foo": float64(1),
 			"bar": float64(2),
@@ -0,0 +1,21 @@
+package expr
+
+import (
+	"github.com/rhysd/actionlint"
+)
+
+// Tracer is notified of the results of the subexpressions of an evaluated expression, for example to
+// show how a complex condition was evaluated.
+type Tracer interface {
+	// OnNode is called with every node once it has been evaluated, so operands are reported before the
+	// operator using them. Nodes skipped by `&&` and `||` are not reported. The result must not be
+	// modified.
+	OnNode(node actionlint.ExprNode, result *EvaluationResult)
+}
+
+// TracerFunc is a Tracer calling the function for every node.
+type TracerFunc func(node actionlint.ExprNode, result *EvaluationResult)
+
+func (f TracerFunc) OnNode(node actionlint.ExprNode, result *EvaluationResult) {
+	f(node, result)
+}
@@ -0,0 +1,80 @@
+package expr
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rhysd/actionlint"
+)
+
+func TestEvaluator_Tracer(t *testing.T) {
+	ctx := MapContext{"github": ContextData{"event_name": "push", "ref": "refs/heads/main"}}
+
+	var trace []string
+	ev := &Evaluator{Tracer: TracerFunc(func(node actionlint.ExprNode, result *EvaluationResult) {
+		var sb strings.Builder
+		writeNode(&sb, node)
+		trace = append(trace, sb.String()+" => "+result.CoerceString())
+	})}
+
+	got, err := ev.Evaluate("github.event_name == 'push' && (contains(github.ref, 'main') || false)", ctx)
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+
+	if got.Value != true {
+		t.Errorf("Evaluate() = %v, want %v", got.Value, true)
+	}
+
+	// The right operand of || is skipped
+	want := []string{
+		"github => Object",
+		"github.event_name => push",
+		"'push' => push",
+		"github.event_name == 'push' => true",
+		"github => Object",
+		"github.ref => refs/heads/main",
+		"'main' => main",
+		"contains(github.ref, 'main') => true",
+		"contains(github.ref, 'main') || false => true",
+		"github.event_name == 'push' && (contains(github.ref, 'main') || false) => true",
+	}
+	if !reflect.DeepEqual(trace, want) {
+		t.Errorf("traced %q, want %q", trace, want)
+	}
+}
+
+func TestEvaluator_Tracer_Error(t *testing.T) {
+	var trace []string
+	ev := &Evaluator{Tracer: TracerFunc(func(node actionlint.ExprNode, result *EvaluationResult) {
+		trace = append(trace, result.CoerceString())
+	})}
+
+	// Nodes failing to evaluate are not traced
+	if _, err := ev.Evaluate("format('{0}', 1, fromJSON('{'))", nil); err == nil {
+		t.Fatalf("Evaluate() did not fail")
+	}
+
+	if want := []string{"{0}", "1", "{"}; !reflect.DeepEqual(trace, want) {
+		t.Errorf("traced %q, want %q", trace, want)
+	}
+}
+
+func TestCachingEvaluator_Tracer(t *testing.T) {
+	calls := 0
+	c := NewCachingEvaluator(10)
+	c.Tracer = TracerFunc(func(node actionlint.ExprNode, result *EvaluationResult) {
+		calls++
+	})
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.Evaluate("!true", nil); err != nil {
+			t.Fatalf("Evaluate() error = %v", err)
+		}
+	}
+
+	if calls != 4 {
+		t.Errorf("OnNode() called %d times, want %d", calls, 4)
+	}
+}
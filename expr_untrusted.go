@@ -0,0 +1,57 @@
+package expr
+
+import (
+	"strings"
+
+	"github.com/rhysd/actionlint"
+)
+
+// checkUntrusted reports the untrusted input read by the given node, which has just been evaluated.
+// Paths are followed like actionlint's UntrustedInputChecker does: index access with anything but a
+// string literal and object filters continue with the `*` element of the path.
+func (e *Evaluator) checkUntrusted(n actionlint.ExprNode, s *evaluation) {
+	var m *actionlint.UntrustedInputMap
+
+	switch tn := n.(type) {
+	case *actionlint.VariableNode:
+		roots := e.UntrustedInputs
+		if roots == nil {
+			roots = actionlint.BuiltinUntrustedInputs
+		}
+
+		m = roots[tn.Name]
+
+	case *actionlint.ObjectDerefNode:
+		m = untrustedChild(s.untrusted[tn.Receiver], tn.Property)
+
+	case *actionlint.IndexAccessNode:
+		if lit, ok := tn.Index.(*actionlint.StringNode); ok {
+			m = untrustedChild(s.untrusted[tn.Operand], strings.ToLower(lit.Value))
+		} else {
+			m = untrustedChild(s.untrusted[tn.Operand], "*")
+		}
+
+	case *actionlint.ArrayDerefNode:
+		m = untrustedChild(s.untrusted[tn.Receiver], "*")
+	}
+
+	if m == nil {
+		return
+	}
+
+	// Only leaves are untrusted, their parents contain trusted properties as well
+	if m.Children == nil {
+		e.OnUntrustedInput(m.String())
+		return
+	}
+
+	s.untrusted[n] = m
+}
+
+func untrustedChild(m *actionlint.UntrustedInputMap, name string) *actionlint.UntrustedInputMap {
+	if m == nil {
+		return nil
+	}
+
+	return m.Children[name]
+}
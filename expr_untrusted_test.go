@@ -0,0 +1,84 @@
+package expr
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/rhysd/actionlint"
+)
+
+func TestEvaluator_OnUntrustedInput(t *testing.T) {
+	ctx := MapContext{
+		"github": ContextData{
+			"sha":      "abc",
+			"head_ref": "feature",
+			"event": ContextData{
+				"issue": ContextData{
+					"title":  "Title",
+					"number": float64(1),
+				},
+				"commits": []interface{}{
+					ContextData{"message": "Fix", "id": "1"},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{"github.event.issue.title", []string{"github.event.issue.title"}},
+		{"github['event']['issue']['TITLE']", []string{"github.event.issue.title"}},
+		{"GITHUB.Event.Issue.Title == 'x'", []string{"github.event.issue.title"}},
+		{"format('{0} {1}', github.head_ref, github.sha)", []string{"github.head_ref"}},
+		{"github.event.commits[0].message", []string{"github.event.commits.*.message"}},
+		{"contains(github.event.commits.*.message, 'fix')", []string{"github.event.commits.*.message"}},
+		{"github.event.pull_request.body", []string{"github.event.pull_request.body"}},
+		{"github.sha", nil},
+		{"github.event.issue.number", nil},
+		{"github.event.issue", nil},
+		{"github.event.commits[0].id", nil},
+		{"env.title", nil},
+
+		// Not read because of short-circuiting
+		{"false && github.event.issue.title", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			var got []string
+			ev := &Evaluator{OnUntrustedInput: func(path string) {
+				got = append(got, path)
+			}}
+
+			if _, err := ev.Evaluate(tt.input, ctx); err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("OnUntrustedInput() called with %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluator_UntrustedInputs(t *testing.T) {
+	roots := actionlint.UntrustedInputSearchRoots{}
+	roots.AddRoot(actionlint.NewUntrustedInputMap("inputs", actionlint.NewUntrustedInputMap("name")))
+
+	var got []string
+	ev := &Evaluator{
+		UntrustedInputs: roots,
+		OnUntrustedInput: func(path string) {
+			got = append(got, path)
+		},
+	}
+
+	if _, err := ev.Evaluate("inputs.name || github.event.issue.title", nil); err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+
+	if want := []string{"inputs.name"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("OnUntrustedInput() called with %v, want %v", got, want)
+	}
+}
@@ -1,11 +1,13 @@
 package expr
 
 import (
+	"encoding/json"
 	"math"
+	"math/big"
 	"strconv"
 	"strings"
-
-	"github.com/rhysd/actionlint"
+	"unicode"
+	"unicode/utf8"
 )
 
 // parseNumber attempts to follow Javascript rules for coercing a string into a number
@@ -17,33 +19,96 @@ func parseNumber(str string) float64 {
 		return 0.0
 	}
 
-	if v, err := strconv.ParseFloat(str, 64); err == nil {
+	if isDecimalLiteral(str) {
+		// Numbers out of range are infinite
+		v, _ := strconv.ParseFloat(str, 64)
 		return v
 	}
 
-	if str[0] == '0' && len(str) > 2 && str[1] == 'x' && strAll(str[2:], func(x rune) bool { return (x >= '0' && x <= '9') || (x >= 'a' && x <= 'f') || (x >= 'A' && x <= 'F') }) {
-		if v, err := strconv.ParseInt(str[2:], 16, 64); err == nil {
-			return float64(v)
+	if len(str) > 2 && str[0] == '0' {
+		base := 0
+		switch str[1] {
+		case 'x', 'X':
+			base = 16
+		case 'o', 'O':
+			base = 8
+		case 'b', 'B':
+			base = 2
 		}
 
-		// Exceeds range
+		if base != 0 && strAll(str[2:], func(x rune) bool { return digitValue(x) < base }) {
+			// Large values are rounded to the nearest float like in Javascript
+			i, _ := new(big.Int).SetString(str[2:], base)
+			v, _ := new(big.Float).SetInt(i).Float64()
+			return v
+		}
 	}
 
-	if str[0] == '0' && len(str) > 2 && str[1] == 'o' && strAll(str[2:], func(x rune) bool { return x >= '0' && x <= '7' }) {
-		if v, err := strconv.ParseInt(str[2:], 8, 32); err == nil {
-			return float64(v)
-		}
+	return math.NaN()
+}
+
+// isDecimalLiteral reports whether str is a decimal number as accepted by Number() in Javascript: an
+// optional sign followed by `Infinity` or by digits with an optional fraction and exponent. Unlike
+// strconv.ParseFloat, it does not accept underscores, hexadecimal floats, or spellings like `inf` and
+// `nan`.
+func isDecimalLiteral(str string) bool {
+	if str != "" && (str[0] == '+' || str[0] == '-') {
+		str = str[1:]
 	}
 
 	if str == "Infinity" {
-		return math.Inf(1)
+		return true
 	}
 
-	if str == "-Infinity" {
-		return math.Inf(-1)
+	i, digits := 0, 0
+	for ; i < len(str) && isDigit(str[i]); i++ {
+		digits++
 	}
 
-	return math.NaN()
+	if i < len(str) && str[i] == '.' {
+		for i++; i < len(str) && isDigit(str[i]); i++ {
+			digits++
+		}
+	}
+
+	if digits == 0 {
+		return false
+	}
+
+	if i < len(str) && (str[i] == 'e' || str[i] == 'E') {
+		i++
+		if i < len(str) && (str[i] == '+' || str[i] == '-') {
+			i++
+		}
+
+		exp := i
+		for ; i < len(str) && isDigit(str[i]); i++ {
+		}
+
+		if i == exp {
+			return false
+		}
+	}
+
+	return i == len(str)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// digitValue returns the value of the hexadecimal digit r, or 16 if r is not a digit.
+func digitValue(r rune) int {
+	switch {
+	case r >= '0' && r <= '9':
+		return int(r - '0')
+	case r >= 'a' && r <= 'f':
+		return int(r-'a') + 10
+	case r >= 'A' && r <= 'F':
+		return int(r-'A') + 10
+	}
+
+	return 16
 }
 
 func strAll(str string, f func(r rune) bool) bool {
@@ -57,25 +122,96 @@ func strAll(str string, f func(r rune) bool) bool {
 }
 
 func convertToNumber(v interface{}) float64 {
-	ltype := getExprType(v)
-
-	switch ltype.(type) {
-	case *actionlint.NullType:
+	switch vt := v.(type) {
+	case nil:
 		return float64(0)
 
-	case *actionlint.BoolType:
-		if v.(bool) {
+	case bool:
+		if vt {
 			return float64(1)
 		} else {
 			return float64(0)
 		}
 
-	case *actionlint.NumberType:
-		return v.(float64)
+	case float64:
+		return vt
 
-	case *actionlint.StringType:
-		return parseNumber(v.(string))
+	case json.Number:
+		// Numbers out of range are infinite
+		f, _ := strconv.ParseFloat(string(vt), 64)
+		return f
+
+	case string, secretString, maskedString:
+		return parseNumber(toString(v))
 	}
 
+	// Arrays and objects
 	return math.NaN()
 }
+
+// formatNumber converts a number to a string like GitHub does, which formats numbers with the C#
+// format string `G15`: at most 15 significant digits without trailing zeros, and exponential form for
+// numbers with an exponent of 15 and above or below -5, like `0.333333333333333` for 1/3 and `1E+21`
+// for 1e21.
+func formatNumber(f float64) string {
+	// Preserve compat with C# implementation, -0 is printed as 0
+	if f == 0 {
+		return "0"
+	}
+
+	// Spelled like C# does, Go would print +Inf and -Inf
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	}
+
+	// Integers are common and can be printed faster, they have 15 digits at most below 1e15
+	if abs := math.Abs(f); abs < 1e15 && f == math.Trunc(f) {
+		return strconv.FormatInt(int64(f), 10)
+	}
+
+	// Round to 15 significant digits first, rounding can change the exponent, like for 9.9999999999999999
+	s := strconv.FormatFloat(f, 'e', 14, 64)
+	mantissa, exp, _ := strings.Cut(s, "e")
+	e, _ := strconv.Atoi(exp)
+
+	if e >= 15 || e < -5 {
+		// C# prints at least two exponent digits, like Go does
+		return trimFractionZeros(mantissa) + "E" + exp
+	}
+
+	return trimFractionZeros(strconv.FormatFloat(f, 'f', 14-e, 64))
+}
+
+// trimFractionZeros removes trailing zeros of the fractional part of a formatted number, and the
+// decimal point if no fractional digits remain.
+func trimFractionZeros(s string) string {
+	if !strings.Contains(s, ".") {
+		return s
+	}
+
+	return strings.TrimSuffix(strings.TrimRight(s, "0"), ".")
+}
+
+// equalIgnoreCase reports whether a and b are equal when ignoring the case, like the ordinal
+// case-insensitive comparison used by GitHub. Every character is converted to upper case on its own,
+// without the language-specific or multi-character rules of full Unicode case folding. For example, `ß`
+// is not equal to `SS`, the Turkish `İ` is not equal to `i`, and the Kelvin sign `K` is not equal to
+// `k`.
+func equalIgnoreCase(a, b string) bool {
+	for a != "" && b != "" {
+		ra, na := utf8.DecodeRuneInString(a)
+		rb, nb := utf8.DecodeRuneInString(b)
+		if a[:na] != b[:nb] && (ra == utf8.RuneError || rb == utf8.RuneError || unicode.ToUpper(ra) != unicode.ToUpper(rb)) {
+			return false
+		}
+
+		a, b = a[na:], b[nb:]
+	}
+
+	return a == b
+}
@@ -76,12 +76,113 @@ func Test_parseNumber(t *testing.T) {
 		{"neg float", args{"-1.5"}, -1.5},
 		{"hex", args{"0xA"}, 10},
 		{"oct", args{"0o10"}, 8},
+		{"upper case hex", args{"0X1F"}, 31},
+		{"binary", args{"0b11"}, 3},
+		{"upper case binary", args{"0B11"}, 3},
+		{"large hex", args{"0x10000000000000000"}, 1 << 64},
+		{"leading dot", args{".5"}, 0.5},
+		{"trailing dot", args{"5."}, 5},
+		{"exponent", args{"1E3"}, 1000},
+		{"out of range", args{"1e400"}, math.Inf(1)},
+		{"infinity", args{"Infinity"}, math.Inf(1)},
+		{"signed infinity", args{"+Infinity"}, math.Inf(1)},
+		{"neg infinity", args{"-Infinity"}, math.Inf(-1)},
+		{"underscore", args{"1_0"}, math.NaN()},
+		{"hex underscore", args{"0x1_0"}, math.NaN()},
+		{"hex float", args{"0x1p3"}, math.NaN()},
+		{"signed hex", args{"-0x10"}, math.NaN()},
+		{"invalid binary", args{"0b12"}, math.NaN()},
+		{"inf", args{"inf"}, math.NaN()},
+		{"neg inf", args{"-inf"}, math.NaN()},
+		{"lower case infinity", args{"infinity"}, math.NaN()},
+		{"nan", args{"nan"}, math.NaN()},
+		{"NaN", args{"NaN"}, math.NaN()},
+		{"dot", args{"."}, math.NaN()},
+		{"missing exponent", args{"1e"}, math.NaN()},
+		{"sign only", args{"-"}, math.NaN()},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := parseNumber(tt.args.str); got != tt.want {
+			if got := parseNumber(tt.args.str); math.IsNaN(tt.want) {
+				if !math.IsNaN(got) {
+					t.Errorf("parseNumber() = %v, want %v", got, tt.want)
+				}
+			} else if got != tt.want {
 				t.Errorf("parseNumber() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
+
+func Test_formatNumber(t *testing.T) {
+	tests := []struct {
+		name string
+		f    float64
+		want string
+	}{
+		{"zero", 0, "0"},
+		{"negative zero", math.Copysign(0, -1), "0"},
+		{"integer", 3, "3"},
+		{"negative integer", -42, "-42"},
+		{"whole float", 3.0, "3"},
+		{"float", 3.5, "3.5"},
+		{"short fraction", 0.1, "0.1"},
+		{"repeating fraction", 1.0 / 3, "0.333333333333333"},
+		{"rounded repeating fraction", 2.0 / 3, "0.666666666666667"},
+		{"quotient", 10.0 / 4, "2.5"},
+		{"inexact sum", 0.30000000000000004, "0.3"},
+		{"rounding up the exponent", 999.9999999999999, "1000"},
+		{"large integer", 1609459200000, "1609459200000"},
+		{"largest plain integer", 999999999999999, "999999999999999"},
+		{"integer with 16 digits", 1e15, "1E+15"},
+		{"largest exact integer", 9007199254740991, "9.00719925474099E+15"},
+		{"smallest exact integer", -9007199254740991, "-9.00719925474099E+15"},
+		{"large number", 1e21, "1E+21"},
+		{"large fractional number", 1.5e300, "1.5E+300"},
+		{"small number", 0.00001, "0.00001"},
+		{"smaller number", 0.000001, "1E-06"},
+		{"negative very small number", -1.25e-10, "-1.25E-10"},
+		{"fraction with 15 digits", 1234.56789012345678, "1234.56789012346"},
+		{"NaN", math.NaN(), "NaN"},
+		{"infinity", math.Inf(1), "Infinity"},
+		{"negative infinity", math.Inf(-1), "-Infinity"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatNumber(tt.f); got != tt.want {
+				t.Errorf("formatNumber() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_equalIgnoreCase(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"", "", true},
+		{"abc", "ABC", true},
+		{"abc", "ab", false},
+		{"ab", "abc", false},
+		{"naïve", "NAÏVE", true},
+		{"Ωmega", "ωMEGA", true},
+		{"σοφος", "ΣΟΦΟΣ", true},
+		{"ΣΟΦΟΣ", "σοφος", true},
+		{"ǆ", "Ǆ", true},
+		{"👍🏽", "👍🏽", true},
+		{"👍🏽", "👍", false},
+		{"straße", "STRASSE", false},
+		{"İstanbul", "istanbul", false},
+		{"ıi", "II", true},
+		{"\u212a", "k", false},
+		{"\xff", "\xfe", false},
+		{"\xff", "\xff", true},
+		{"\ufffd", "\xff", false},
+	}
+	for _, tt := range tests {
+		if got := equalIgnoreCase(tt.a, tt.b); got != tt.want {
+			t.Errorf("equalIgnoreCase(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package expr
+
+import (
+	"sort"
+
+	"github.com/rhysd/actionlint"
+)
+
+// Walk parses the given expression, without the surrounding `${{ }}`, and calls fn for every node of
+// its syntax tree in depth-first order, parents before their children. If fn returns false, the
+// children of the node are skipped.
+func Walk(expr string, fn func(node actionlint.ExprNode) bool) error {
+	n, err := parse(expr, 0)
+	if err != nil {
+		return err
+	}
+
+	WalkNode(n, fn)
+
+	return nil
+}
+
+// WalkNode calls fn for every node of an already parsed expression like Walk.
+func WalkNode(n actionlint.ExprNode, fn func(node actionlint.ExprNode) bool) {
+	if !fn(n) {
+		return
+	}
+
+	switch tn := n.(type) {
+	case *actionlint.ObjectDerefNode:
+		WalkNode(tn.Receiver, fn)
+
+	case *actionlint.ArrayDerefNode:
+		WalkNode(tn.Receiver, fn)
+
+	case *actionlint.IndexAccessNode:
+		WalkNode(tn.Operand, fn)
+		WalkNode(tn.Index, fn)
+
+	case *actionlint.FuncCallNode:
+		for _, arg := range tn.Args {
+			WalkNode(arg, fn)
+		}
+
+	case *actionlint.NotOpNode:
+		WalkNode(tn.Operand, fn)
+
+	case *actionlint.CompareOpNode:
+		WalkNode(tn.Left, fn)
+		WalkNode(tn.Right, fn)
+
+	case *actionlint.LogicalOpNode:
+		WalkNode(tn.Left, fn)
+		WalkNode(tn.Right, fn)
+	}
+}
+
+// ReferencedContexts returns the sorted names of the contexts the given expression references, like
+// `github` and `secrets` for `github.event_name == 'push' && secrets.TOKEN`. Names are lower case.
+func ReferencedContexts(expr string) ([]string, error) {
+	seen := map[string]bool{}
+	names := []string{}
+
+	err := Walk(expr, func(node actionlint.ExprNode) bool {
+		if v, ok := node.(*actionlint.VariableNode); ok && !seen[v.Name] {
+			seen[v.Name] = true
+			names = append(names, v.Name)
+		}
+
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(names)
+
+	return names, nil
+}
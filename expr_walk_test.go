@@ -0,0 +1,89 @@
+package expr
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/rhysd/actionlint"
+)
+
+func TestReferencedContexts(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{"1 == 1", []string{}},
+		{"github.sha", []string{"github"}},
+		{"GITHUB.sha == github.ref", []string{"github"}},
+		{"github.event_name == 'push' && (secrets.TOKEN || env.FALLBACK) != ''", []string{"env", "github", "secrets"}},
+		{"contains(fromJSON(needs.setup.outputs.list), matrix.os)", []string{"matrix", "needs"}},
+		{"steps.*.outcome[inputs.index]", []string{"inputs", "steps"}},
+		{"!vars.DISABLED", []string{"vars"}},
+		{"'github.sha'", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ReferencedContexts(tt.input)
+			if err != nil {
+				t.Fatalf("ReferencedContexts() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReferencedContexts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := ReferencedContexts("github.sha =="); err == nil {
+		t.Errorf("ReferencedContexts() did not fail for invalid expression")
+	}
+}
+
+func TestWalk(t *testing.T) {
+	var visited []string
+	err := Walk("startsWith(github.ref, 'refs/') && !fromJSON(env.X)[0]", func(node actionlint.ExprNode) bool {
+		visited = append(visited, fmt.Sprintf("%T", node))
+		return true
+	})
+	if err != nil {
+		t.Fatalf("Walk() error = %v", err)
+	}
+
+	want := []string{
+		"*actionlint.LogicalOpNode",
+		"*actionlint.FuncCallNode",
+		"*actionlint.ObjectDerefNode",
+		"*actionlint.VariableNode",
+		"*actionlint.StringNode",
+		"*actionlint.NotOpNode",
+		"*actionlint.IndexAccessNode",
+		"*actionlint.FuncCallNode",
+		"*actionlint.ObjectDerefNode",
+		"*actionlint.VariableNode",
+		"*actionlint.IntNode",
+	}
+	if !reflect.DeepEqual(visited, want) {
+		t.Errorf("Walk() visited %v, want %v", visited, want)
+	}
+}
+
+func TestWalk_SkipChildren(t *testing.T) {
+	// Arguments of function calls are skipped
+	var names []string
+	err := Walk("github.sha == format('{0}', env.X) || inputs.y", func(node actionlint.ExprNode) bool {
+		if v, ok := node.(*actionlint.VariableNode); ok {
+			names = append(names, v.Name)
+		}
+
+		_, call := node.(*actionlint.FuncCallNode)
+		return !call
+	})
+	if err != nil {
+		t.Fatalf("Walk() error = %v", err)
+	}
+
+	if want := []string{"github", "inputs"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Walk() visited %v, want %v", names, want)
+	}
+}